@@ -1,11 +1,14 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"os"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/eu-ovictor/b3-market-data/db"
 	"github.com/gofiber/fiber/v2"
@@ -15,20 +18,174 @@ type api struct {
 	db db.DB
 }
 
+// dateRange reads the from and to query parameters, inclusive ISO dates that
+// may be left empty. date is still accepted in place of from.
+func dateRange(c *fiber.Ctx) (string, string, error) {
+	from := c.Query("from", c.Query("date"))
+	to := c.Query("to")
+
+	var start, end time.Time
+
+	for _, d := range []struct {
+		name  string
+		value string
+		t     *time.Time
+	}{{"from", from, &start}, {"to", to, &end}} {
+		if d.value == "" {
+			continue
+		}
+
+		t, err := time.Parse(time.DateOnly, d.value)
+		if err != nil {
+			return "", "", fmt.Errorf("%w: %s date %q, expected YYYY-MM-DD", db.ErrInvalidArgument, d.name, d.value)
+		}
+
+		*d.t = t
+	}
+
+	if from != "" && to != "" && end.Before(start) {
+		return "", "", fmt.Errorf("%w: from %s is after to %s", db.ErrInvalidArgument, from, to)
+	}
+
+	return from, to, nil
+}
+
+const (
+	defaultLimit = 1000
+	maxLimit     = 10000
+)
+
+// v1Prefix is the prefix of the versioned routes.
+const v1Prefix = "/v1"
+
+// nextCursorHeader carries the cursor of the next page of /trades, and is
+// left out on the last page.
+const nextCursorHeader = "X-Next-Cursor"
+
+// tradeQuery reads the filters, sort and page of /trades. sort is a field
+// optionally followed by :asc or :desc, as in max_daily_volume:desc. Pages
+// have defaultLimit summaries unless limit is given, except on the unprefixed
+// /trades without cursor or sort, which returned every summary before pages
+// existed, to clients unaware of nextCursorHeader.
+func tradeQuery(c *fiber.Ctx) (db.TradeQuery, error) {
+	from, to, err := dateRange(c)
+	if err != nil {
+		return db.TradeQuery{}, err
+	}
+
+	q := db.TradeQuery{
+		From:   from,
+		To:     to,
+		Ticker: c.Query("ticker"),
+		Cursor: c.Query("cursor"),
+	}
+
+	if strings.HasPrefix(c.Route().Path, v1Prefix) || q.Cursor != "" || c.Query("sort") != "" {
+		q.Limit = defaultLimit
+	}
+
+	if limit := c.Query("limit"); limit != "" {
+		q.Limit, err = strconv.Atoi(limit)
+		if err != nil || q.Limit < 1 || q.Limit > maxLimit {
+			return db.TradeQuery{}, fmt.Errorf("%w: limit %q, expected a number from 1 to %d", db.ErrInvalidArgument, limit, maxLimit)
+		}
+	}
+
+	field, direction, _ := strings.Cut(c.Query("sort"), ":")
+	q.Sort = field
+
+	switch direction {
+	case "", "asc":
+	case "desc":
+		q.Desc = true
+	default:
+		return db.TradeQuery{}, fmt.Errorf("%w: sort direction %q, expected asc or desc", db.ErrInvalidArgument, direction)
+	}
+
+	return q, nil
+}
+
+// statFields are the statistics of a summary left out unless requested in
+// the fields query parameter, each with a function dropping it.
+var statFields = []struct {
+	name string
+	drop func(*db.TradeSummary)
+}{
+	{"open", func(t *db.TradeSummary) { t.Open = nil }},
+	{"close", func(t *db.TradeSummary) { t.Close = nil }},
+	{"min_range_value", func(t *db.TradeSummary) { t.MinRangeValue = nil }},
+	{"vwap", func(t *db.TradeSummary) { t.VWAP = nil }},
+	{"volume", func(t *db.TradeSummary) { t.Volume = nil }},
+	{"financial_volume", func(t *db.TradeSummary) { t.FinancialVolume = nil }},
+	{"trade_count", func(t *db.TradeSummary) { t.TradeCount = nil }},
+	{"avg_trade_size", func(t *db.TradeSummary) { t.AvgTradeSize = nil }},
+}
+
+// selectFields reads the statistics requested in the fields query parameter,
+// a comma separated list as in open,close,vwap, returning a function that
+// drops the others from a summary.
+func selectFields(c *fiber.Ctx) (func(*db.TradeSummary), error) {
+	requested := map[string]bool{}
+
+	if fields := c.Query("fields"); fields != "" {
+		for _, field := range strings.Split(fields, ",") {
+			requested[field] = true
+		}
+	}
+
+	names := make([]string, 0, len(statFields))
+	drops := []func(*db.TradeSummary){}
+
+	for _, f := range statFields {
+		names = append(names, f.name)
+
+		if requested[f.name] {
+			delete(requested, f.name)
+			continue
+		}
+
+		drops = append(drops, f.drop)
+	}
+
+	for field := range requested {
+		return nil, fmt.Errorf("%w: field %q, expected any of %s", db.ErrInvalidArgument, field, strings.Join(names, ","))
+	}
+
+	return func(t *db.TradeSummary) {
+		for _, drop := range drops {
+			drop(t)
+		}
+	}, nil
+}
+
 func (app *api) fetchTradesHandler(c *fiber.Ctx) error {
-	date := c.Query("date")
+	q, err := tradeQuery(c)
+	if err != nil {
+		return handleError(c, err)
+	}
+
+	dropFields, err := selectFields(c)
+	if err != nil {
+		return handleError(c, err)
+	}
 
-	trades, err := app.db.FetchTrades(date)
+	page, err := app.db.FetchTrades(c.UserContext(), q)
 
 	if err != nil {
-		fmt.Fprintln(os.Stderr, err)
-		return c.SendStatus(http.StatusInternalServerError)
+		return handleError(c, err)
+	}
+
+	for i := range page.Trades {
+		dropFields(&page.Trades[i])
 	}
 
-	responseBody, err := json.Marshal(trades)
+	responseBody, err := json.Marshal(page.Trades)
 	if err != nil {
-		fmt.Fprintln(os.Stderr, err)
-		return c.SendStatus(http.StatusInternalServerError)
+		return handleError(c, err)
+	}
+
+	if page.NextCursor != "" {
+		c.Set(nextCursorHeader, page.NextCursor)
 	}
 
 	c.Set("Content-Type", "application/json")
@@ -39,17 +196,27 @@ func (app *api) fetchTradesHandler(c *fiber.Ctx) error {
 func (app *api) getTradeHandler(c *fiber.Ctx) error {
 	ticker := c.Params("ticker")
 
-	date := c.Query("date")
+	from, to, err := dateRange(c)
+	if err != nil {
+		return handleError(c, err)
+	}
+
+	dropFields, err := selectFields(c)
+	if err != nil {
+		return handleError(c, err)
+	}
 
-	trade, err := app.db.GetTrade(ticker, date)
+	trade, err := app.db.GetTrade(c.UserContext(), ticker, from, to)
 	if err != nil {
-		return c.SendStatus(http.StatusInternalServerError)
+		return handleError(c, err)
 
 	}
 
+	dropFields(&trade)
+
 	responseBody, err := json.Marshal(trade)
 	if err != nil {
-		return c.SendStatus(http.StatusInternalServerError)
+		return handleError(c, err)
 	}
 
 	c.Set("Content-Type", "application/json")
@@ -57,24 +224,155 @@ func (app *api) getTradeHandler(c *fiber.Ctx) error {
 	return c.Send(responseBody)
 }
 
-func Serve(db db.DB, p string) error {
-	if !strings.HasPrefix(p, ":") {
-		p = ":" + p
+func (app *api) getTradeDaysHandler(c *fiber.Ctx) error {
+	ticker := c.Params("ticker")
+
+	from, to, err := dateRange(c)
+	if err != nil {
+		return handleError(c, err)
+	}
+
+	days, err := app.db.GetTradeDays(c.UserContext(), ticker, from, to)
+	if err != nil {
+		return handleError(c, err)
+	}
+
+	if len(days) == 0 {
+		return handleError(c, fmt.Errorf("%w: no trades for %s", db.ErrNotFound, ticker))
+	}
+
+	responseBody, err := json.Marshal(days)
+	if err != nil {
+		return handleError(c, err)
+	}
+
+	c.Set("Content-Type", "application/json")
+
+	return c.Send(responseBody)
+}
+
+func (app *api) getCandlesHandler(c *fiber.Ctx) error {
+	ticker := c.Params("ticker")
+
+	interval := c.Query("interval", "1m")
+	from, to, err := dateRange(c)
+	if err != nil {
+		return handleError(c, err)
+	}
+
+	candles, err := app.db.GetCandles(c.UserContext(), ticker, interval, from, to)
+	if err != nil {
+		return handleError(c, err)
+	}
+
+	responseBody, err := json.Marshal(candles)
+	if err != nil {
+		return handleError(c, err)
+	}
+
+	c.Set("Content-Type", "application/json")
+
+	return c.Send(responseBody)
+}
+
+// healthzHandler tells the process is alive.
+func healthzHandler(c *fiber.Ctx) error {
+	c.Set("Content-Type", "application/json")
+
+	return c.SendString(`{"status":"ok"}`)
+}
+
+// readyzHandler tells the database is reachable and has the summaries served
+// by the API, so the process can receive traffic.
+func (app *api) readyzHandler(c *fiber.Ctx) error {
+	if err := app.db.Ready(c.UserContext()); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		// the error may name hosts and users, so clients only get a fixed message
+		return sendError(c, http.StatusServiceUnavailable, codeUnavailable, "database not ready")
 	}
 
+	c.Set("Content-Type", "application/json")
+
+	return c.SendString(`{"status":"ready"}`)
+}
+
+// routes registers the routes of the API on r.
+func (app *api) routes(r fiber.Router) {
+	r.Get("/trades", app.fetchTradesHandler)
+
+	// registered before /trades/:ticker, which would match it otherwise
+	r.Get("/trades/compare", app.compareHandler)
+
+	r.Get("/trades/:ticker", app.getTradeHandler)
+
+	r.Get("/trades/:ticker/daily", app.getTradeDaysHandler)
+
+	r.Get("/trades/:ticker/candles", app.getCandlesHandler)
+
+	r.Get("/trades/:ticker/ticks", app.getTicksHandler)
+
+	r.Get("/rankings", app.rankingsHandler)
+}
+
+func newRouter(db db.DB) *fiber.App {
 	app := api{
 		db: db,
 	}
 
-	router := fiber.New()
+	router := fiber.New(fiber.Config{
+		ErrorHandler: errorHandler,
+	})
+
+	m := newMetrics(db)
+
+	router.Use(m.middleware)
+
+	router.Get("/healthz", healthzHandler)
+
+	router.Get("/readyz", app.readyzHandler)
 
-	router.Get("/trades", app.fetchTradesHandler)
+	router.Get("/metrics", m.handler())
 
-	router.Get("/trades/:ticker", app.getTradeHandler)
+	router.Get("/openapi.json", openAPIHandler)
+
+	router.Get("/docs", docsHandler)
+
+	app.routes(router.Group(v1Prefix))
+
+	// the routes are also served without a prefix, as before /v1 existed
+	app.routes(router)
+
+	return router
+}
+
+// shutdownTimeout is how long Serve waits for requests in flight to finish
+// once ctx is done.
+const shutdownTimeout = 30 * time.Second
+
+// Serve spins up the web API on port p until ctx is done, when it stops
+// accepting connections and drains the requests in flight before returning.
+func Serve(ctx context.Context, db db.DB, p string) error {
+	if !strings.HasPrefix(p, ":") {
+		p = ":" + p
+	}
+
+	router := newRouter(db)
+
+	errs := make(chan error, 1)
+
+	go func() {
+		errs <- router.Listen(p)
+	}()
+
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+	}
 
-	if err := router.Listen(p); err != nil {
+	if err := router.ShutdownWithTimeout(shutdownTimeout); err != nil {
 		return err
 	}
 
-	return nil
+	return <-errs
 }
@@ -0,0 +1,438 @@
+package api
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/eu-ovictor/b3-market-data/db"
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestRouter returns a router serving trades from an in-memory DB.
+func newTestRouter(t *testing.T, trades ...db.Trade) *fiber.App {
+	t.Helper()
+
+	m := db.NewMemory()
+
+	if err := m.InsertMany(context.Background(), trades); err != nil {
+		t.Fatalf("expected no error inserting trades, got %s", err)
+	}
+
+	return newRouter(m)
+}
+
+// get sends a GET request to the router and decodes the JSON response, which
+// may be an error, into v.
+func get(t *testing.T, router *fiber.App, path string, v any) int {
+	t.Helper()
+
+	resp, err := router.Test(httptest.NewRequest(http.MethodGet, path, nil))
+	if err != nil {
+		t.Fatalf("expected no error requesting %s, got %s", path, err)
+	}
+	defer resp.Body.Close()
+
+	if v != nil {
+		if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+			t.Fatalf("expected no error decoding %s, got %s", path, err)
+		}
+	}
+
+	return resp.StatusCode
+}
+
+func sessionTime(t *testing.T, offset int, hour int, minute int) time.Time {
+	t.Helper()
+
+	loc, err := time.LoadLocation("America/Sao_Paulo")
+	if err != nil {
+		t.Fatalf("expected no error loading location, got %s", err)
+	}
+
+	return time.Date(2024, 7, 1+offset, hour, minute, 0, 0, loc)
+}
+
+func TestFetchTrades(t *testing.T) {
+	router := newTestRouter(
+		t,
+		db.Trade{Ticker: "PETR4", GrossAmount: 37.5, Quantity: 100, EntryTime: sessionTime(t, 0, 10, 0), TradeID: 1},
+		db.Trade{Ticker: "VALE3", GrossAmount: 60.1, Quantity: 200, EntryTime: sessionTime(t, 1, 10, 0), TradeID: 1},
+	)
+
+	var trades []db.TradeSummary
+
+	status := get(t, router, "/trades", &trades)
+	assert.Equal(t, status, http.StatusOK, "expected status to be %v, got %v", http.StatusOK, status)
+	assert.Equal(t, len(trades), 2, "expected a summary by ticker, got %v", trades)
+
+	status = get(t, router, "/trades?date=2024-07-02", &trades)
+	assert.Equal(t, status, http.StatusOK, "expected status to be %v, got %v", http.StatusOK, status)
+	assert.Equal(t, len(trades), 1, "expected a single summary, got %v", trades)
+
+	status = get(t, router, "/trades?from=2024-07-01&to=2024-07-01", &trades)
+	assert.Equal(t, status, http.StatusOK, "expected status to be %v, got %v", http.StatusOK, status)
+	assert.Equal(t, len(trades), 1, "expected a single summary, got %v", trades)
+	assert.Equal(t, trades[0].Ticker, "PETR4", "expected the summary of PETR4, got %v", trades)
+}
+
+func TestFetchTradesPages(t *testing.T) {
+	router := newTestRouter(
+		t,
+		db.Trade{Ticker: "PETR3", GrossAmount: 37.5, Quantity: 300, EntryTime: sessionTime(t, 0, 10, 0), TradeID: 1},
+		db.Trade{Ticker: "PETR4", GrossAmount: 37.5, Quantity: 100, EntryTime: sessionTime(t, 0, 10, 0), TradeID: 1},
+		db.Trade{Ticker: "VALE3", GrossAmount: 60.1, Quantity: 200, EntryTime: sessionTime(t, 0, 10, 0), TradeID: 1},
+	)
+
+	resp, err := router.Test(httptest.NewRequest(http.MethodGet, "/trades?sort=max_daily_volume:desc&limit=2", nil))
+	assert.NoError(t, err, "expected no error requesting the first page, got %s", err)
+	defer resp.Body.Close()
+
+	var trades []db.TradeSummary
+
+	err = json.NewDecoder(resp.Body).Decode(&trades)
+	assert.NoError(t, err, "expected no error decoding the first page, got %s", err)
+	assert.Equal(t, len(trades), 2, "expected a page of 2 summaries, got %v", trades)
+	assert.Equal(t, trades[0].Ticker, "PETR3", "expected the highest volume first, got %v", trades)
+
+	cursor := resp.Header.Get(nextCursorHeader)
+	assert.NotEmpty(t, cursor, "expected a cursor for the next page")
+
+	status := get(t, router, "/trades?sort=max_daily_volume:desc&limit=2&cursor="+cursor, &trades)
+	assert.Equal(t, status, http.StatusOK, "expected status to be %v, got %v", http.StatusOK, status)
+	assert.Equal(t, len(trades), 1, "expected the last summary, got %v", trades)
+	assert.Equal(t, trades[0].Ticker, "PETR4", "expected the lowest volume last, got %v", trades)
+
+	status = get(t, router, "/trades?ticker=PETR*", &trades)
+	assert.Equal(t, status, http.StatusOK, "expected status to be %v, got %v", http.StatusOK, status)
+	assert.Equal(t, len(trades), 2, "expected the summaries of PETR tickers, got %v", trades)
+
+	for _, path := range []string{
+		"/trades?limit=0",
+		"/trades?limit=many",
+		"/trades?sort=quantity",
+		"/trades?sort=ticker:up",
+		"/trades?cursor=invalid",
+	} {
+		status := get(t, router, path, nil)
+		assert.Equal(t, status, http.StatusBadRequest, "expected status of %s to be %v, got %v", path, http.StatusBadRequest, status)
+	}
+}
+
+func TestFetchTradesDefaultLimit(t *testing.T) {
+	trades := make([]db.Trade, defaultLimit+1)
+	for i := range trades {
+		trades[i] = db.Trade{Ticker: fmt.Sprintf("T%04d", i), GrossAmount: 1, Quantity: 1, EntryTime: sessionTime(t, 0, 10, 0), TradeID: 1}
+	}
+
+	router := newTestRouter(t, trades...)
+
+	for _, c := range []struct {
+		path     string
+		expected int
+	}{
+		// clients of the unprefixed route from before pages get every summary
+		{"/trades", defaultLimit + 1},
+		{"/trades?ticker=T*", defaultLimit + 1},
+		{"/trades?sort=ticker", defaultLimit},
+		{"/v1/trades", defaultLimit},
+	} {
+		var summaries []db.TradeSummary
+
+		status := get(t, router, c.path, &summaries)
+		assert.Equal(t, status, http.StatusOK, "expected status of %s to be %v, got %v", c.path, http.StatusOK, status)
+		assert.Equal(t, len(summaries), c.expected, "expected %v summaries from %s, got %v", c.expected, c.path, len(summaries))
+	}
+}
+
+func TestInvalidDateRange(t *testing.T) {
+	router := newTestRouter(t)
+
+	for _, path := range []string{
+		"/trades?from=01/07/2024",
+		"/trades?date=yesterday",
+		"/trades/PETR4?to=2024-13-01",
+		"/trades/PETR4?from=2024-07-02&to=2024-07-01",
+		"/trades/PETR4/candles?from=2024-7-1",
+		"/trades/PETR4/candles?interval=2m",
+	} {
+		var body errorResponse
+
+		status := get(t, router, path, &body)
+		assert.Equal(t, status, http.StatusBadRequest, "expected status of %s to be %v, got %v", path, http.StatusBadRequest, status)
+		assert.Equal(t, body.Error.Code, codeInvalidArgument, "expected an invalid argument error for %s, got %v", path, body)
+		assert.NotEmpty(t, body.Error.Message, "expected an error message for %s, got %v", path, body)
+	}
+}
+
+func TestNotFound(t *testing.T) {
+	router := newTestRouter(
+		t,
+		db.Trade{Ticker: "PETR4", GrossAmount: 37.5, Quantity: 100, EntryTime: sessionTime(t, 0, 10, 0), TradeID: 1},
+	)
+
+	for _, path := range []string{
+		"/trades/VALE3",
+		"/trades/PETR4?from=2024-07-02",
+		"/unknown",
+	} {
+		var body errorResponse
+
+		status := get(t, router, path, &body)
+		assert.Equal(t, status, http.StatusNotFound, "expected status of %s to be %v, got %v", path, http.StatusNotFound, status)
+		assert.Equal(t, body.Error.Code, codeNotFound, "expected a not found error for %s, got %v", path, body)
+	}
+}
+
+func TestGetTrade(t *testing.T) {
+	router := newTestRouter(
+		t,
+		db.Trade{Ticker: "PETR4", GrossAmount: 37.5, Quantity: 100, EntryTime: sessionTime(t, 0, 10, 0), TradeID: 1},
+		db.Trade{Ticker: "PETR4", GrossAmount: 38, Quantity: 50, EntryTime: sessionTime(t, 0, 11, 0), TradeID: 2},
+	)
+
+	var trade db.TradeSummary
+
+	status := get(t, router, "/trades/PETR4", &trade)
+	assert.Equal(t, status, http.StatusOK, "expected status to be %v, got %v", http.StatusOK, status)
+	assert.Equal(t, trade.MaxRangeValue, 38.0, "expected max range value to be %v, got %v", 38.0, trade.MaxRangeValue)
+	assert.Equal(t, trade.MaxDailyVolume, int64(150), "expected max daily volume to be %v, got %v", 150, trade.MaxDailyVolume)
+}
+
+func TestSummaryFields(t *testing.T) {
+	router := newTestRouter(
+		t,
+		db.Trade{Ticker: "PETR4", GrossAmount: 37.5, Quantity: 100, EntryTime: sessionTime(t, 0, 10, 0), TradeID: 1},
+		db.Trade{Ticker: "PETR4", GrossAmount: 38, Quantity: 50, EntryTime: sessionTime(t, 0, 11, 0), TradeID: 2},
+	)
+
+	var trade map[string]any
+
+	status := get(t, router, "/trades/PETR4", &trade)
+	assert.Equal(t, status, http.StatusOK, "expected status to be %v, got %v", http.StatusOK, status)
+	assert.NotContains(t, trade, "vwap", "expected statistics to be left out by default, got %v", trade)
+
+	trade = nil
+
+	status = get(t, router, "/trades/PETR4?fields=open,vwap", &trade)
+	assert.Equal(t, status, http.StatusOK, "expected status to be %v, got %v", http.StatusOK, status)
+	assert.Equal(t, trade["open"], 37.5, "expected open to be %v, got %v", 37.5, trade["open"])
+	assert.InDelta(t, trade["vwap"], 5650.0/150, 1e-9, "expected vwap to be %v, got %v", 5650.0/150, trade["vwap"])
+	assert.NotContains(t, trade, "close", "expected statistics not requested to be left out, got %v", trade)
+
+	var trades []map[string]any
+
+	status = get(t, router, "/trades?fields=trade_count", &trades)
+	assert.Equal(t, status, http.StatusOK, "expected status to be %v, got %v", http.StatusOK, status)
+	assert.Equal(t, trades[0]["trade_count"], 2.0, "expected trade count to be %v, got %v", 2, trades[0]["trade_count"])
+
+	var body errorResponse
+
+	status = get(t, router, "/trades?fields=open,spread", &body)
+	assert.Equal(t, status, http.StatusBadRequest, "expected status to be %v, got %v", http.StatusBadRequest, status)
+	assert.Equal(t, body.Error.Code, codeInvalidArgument, "expected an invalid argument error, got %v", body)
+}
+
+func TestGetTradeDays(t *testing.T) {
+	router := newTestRouter(
+		t,
+		db.Trade{Ticker: "PETR4", GrossAmount: 37.5, Quantity: 100, EntryTime: sessionTime(t, 0, 10, 0), TradeID: 1},
+		db.Trade{Ticker: "PETR4", GrossAmount: 38, Quantity: 50, EntryTime: sessionTime(t, 1, 11, 0), TradeID: 1},
+	)
+
+	var days []db.TradeDay
+
+	status := get(t, router, "/v1/trades/PETR4/daily", &days)
+	assert.Equal(t, status, http.StatusOK, "expected status to be %v, got %v", http.StatusOK, status)
+	assert.Equal(t, len(days), 2, "expected a day for each session, got %v", days)
+	assert.Equal(t, days[1].Date, "2024-07-02", "expected the last day to be %v, got %v", "2024-07-02", days[1].Date)
+
+	days = nil
+
+	status = get(t, router, "/v1/trades/PETR4/daily?from=2024-07-02&to=2024-07-02", &days)
+	assert.Equal(t, status, http.StatusOK, "expected status to be %v, got %v", http.StatusOK, status)
+	assert.Equal(t, len(days), 1, "expected a single day, got %v", days)
+	assert.Equal(t, days[0].Volume, int64(50), "expected volume to be %v, got %v", 50, days[0].Volume)
+
+	for _, path := range []string{"/v1/trades/VALE3/daily", "/v1/trades/PETR4/daily?from=2024-07-03"} {
+		var body errorResponse
+
+		status = get(t, router, path, &body)
+		assert.Equal(t, status, http.StatusNotFound, "expected status of %s to be %v, got %v", path, http.StatusNotFound, status)
+		assert.Equal(t, body.Error.Code, codeNotFound, "expected a not found error, got %v", body)
+	}
+}
+
+func TestGetCandles(t *testing.T) {
+	router := newTestRouter(
+		t,
+		db.Trade{Ticker: "PETR4", GrossAmount: 37.5, Quantity: 100, EntryTime: sessionTime(t, 0, 10, 0), TradeID: 1},
+		db.Trade{Ticker: "PETR4", GrossAmount: 38, Quantity: 50, EntryTime: sessionTime(t, 0, 10, 7), TradeID: 2},
+	)
+
+	var candles []db.Candle
+
+	status := get(t, router, "/trades/PETR4/candles?interval=5m", &candles)
+	assert.Equal(t, status, http.StatusOK, "expected status to be %v, got %v", http.StatusOK, status)
+	assert.Equal(t, len(candles), 2, "expected a candle for each 5 minutes with trades, got %v", candles)
+
+	status = get(t, router, "/trades/PETR4/candles?interval=1h&from=2024-07-02", &candles)
+	assert.Equal(t, status, http.StatusOK, "expected status to be %v, got %v", http.StatusOK, status)
+	assert.Equal(t, len(candles), 0, "expected no candles without trades, got %v", candles)
+}
+
+func TestGetTicks(t *testing.T) {
+	router := newTestRouter(
+		t,
+		db.Trade{Ticker: "PETR4", GrossAmount: 38, Quantity: 50, EntryTime: sessionTime(t, 0, 11, 0), TradeID: 2},
+		db.Trade{Ticker: "PETR4", GrossAmount: 37.5, Quantity: 100, EntryTime: sessionTime(t, 0, 10, 0), TradeID: 1},
+		db.Trade{Ticker: "PETR4", GrossAmount: 39, Quantity: 10, EntryTime: sessionTime(t, 1, 10, 0), TradeID: 1},
+	)
+
+	resp, err := router.Test(httptest.NewRequest(http.MethodGet, "/v1/trades/PETR4/ticks?date=2024-07-01", nil))
+	if err != nil {
+		t.Fatalf("expected no error requesting ticks, got %s", err)
+	}
+	defer resp.Body.Close()
+
+	assert.Equal(t, resp.StatusCode, http.StatusOK, "expected status to be %v, got %v", http.StatusOK, resp.StatusCode)
+	assert.Equal(t, resp.Header.Get("Content-Type"), "application/x-ndjson", "expected NDJSON, got %s", resp.Header.Get("Content-Type"))
+
+	dec := json.NewDecoder(resp.Body)
+	ticks := []db.Tick{}
+
+	for dec.More() {
+		var tick db.Tick
+
+		if err := dec.Decode(&tick); err != nil {
+			t.Fatalf("expected no error decoding a tick, got %s", err)
+		}
+
+		ticks = append(ticks, tick)
+	}
+
+	assert.Equal(t, len(ticks), 2, "expected the trades of the session, got %v", ticks)
+	assert.Equal(t, ticks[0].TradeID, int64(1), "expected ticks in time order, got %v", ticks)
+
+	resp, err = router.Test(httptest.NewRequest(http.MethodGet, "/v1/trades/PETR4/ticks?date=2024-07-01&from_time=10:30&format=csv", nil))
+	if err != nil {
+		t.Fatalf("expected no error requesting ticks, got %s", err)
+	}
+	defer resp.Body.Close()
+
+	records, err := csv.NewReader(resp.Body).ReadAll()
+	assert.NoError(t, err, "expected no error reading the CSV, got %s", err)
+	assert.Equal(t, records, [][]string{
+		{"time", "price", "quantity", "trade_id"},
+		{"2024-07-01T11:00:00-03:00", "38", "50", "2"},
+	}, "expected a header and the trades after 10:30, got %v", records)
+
+	for _, path := range []string{
+		"/v1/trades/PETR4/ticks",
+		"/v1/trades/PETR4/ticks?date=2024-07-01&to_time=noon",
+		"/v1/trades/PETR4/ticks?date=2024-07-01&format=xml",
+	} {
+		var body errorResponse
+
+		status := get(t, router, path, &body)
+		assert.Equal(t, status, http.StatusBadRequest, "expected status of %s to be %v, got %v", path, http.StatusBadRequest, status)
+		assert.Equal(t, body.Error.Code, codeInvalidArgument, "expected an invalid argument error for %s, got %v", path, body)
+	}
+}
+
+func TestCompare(t *testing.T) {
+	trades := []db.Trade{}
+
+	for ticker, closes := range map[string][]float64{
+		"PETR4": {10, 11, 9.9, 10.89},
+		"VALE3": {20, 22, 19.8},
+		"ITUB4": {5, 4.5, 4.95},
+	} {
+		for i, price := range closes {
+			trades = append(trades, db.Trade{Ticker: ticker, GrossAmount: price, Quantity: 100, EntryTime: sessionTime(t, i, 10, 0), TradeID: 1})
+		}
+	}
+
+	router := newTestRouter(t, trades...)
+
+	var c comparison
+
+	status := get(t, router, "/v1/trades/compare?tickers=PETR4,VALE3,ITUB4,PETR4", &c)
+	assert.Equal(t, status, http.StatusOK, "expected status to be %v, got %v", http.StatusOK, status)
+	assert.Equal(t, len(c.Dates), 4, "expected the days any ticker traded, got %v", c.Dates)
+	assert.Equal(t, len(c.Series), 3, "expected a series by ticker, got %v", c.Series)
+
+	vale := c.Series[1]
+	assert.Equal(t, vale.Ticker, "VALE3", "expected series in the order of tickers, got %v", vale.Ticker)
+	assert.Nil(t, vale.Days[3], "expected a null day when the ticker did not trade, got %v", vale.Days[3])
+	assert.Nil(t, vale.Returns[0], "expected no return on the first day, got %v", vale.Returns[0])
+	assert.InDelta(t, *vale.Returns[1], 0.1, 1e-9, "expected a return of %v, got %v", 0.1, *vale.Returns[1])
+	assert.InDelta(t, *c.Series[0].TotalReturn, 0.089, 1e-9, "expected a total return of %v, got %v", 0.089, *c.Series[0].TotalReturn)
+
+	assert.InDelta(t, *c.Correlation[0][1], 1, 1e-9, "expected PETR4 and VALE3 to be correlated, got %v", *c.Correlation[0][1])
+	assert.InDelta(t, *c.Correlation[0][2], -1, 1e-9, "expected PETR4 and ITUB4 to be inversely correlated, got %v", *c.Correlation[0][2])
+
+	c = comparison{}
+
+	status = get(t, router, "/v1/trades/compare?tickers=PETR4,VALE3&from=2024-07-02&to=2024-07-02", &c)
+	assert.Equal(t, status, http.StatusOK, "expected status to be %v, got %v", http.StatusOK, status)
+	assert.Nil(t, c.Correlation[0][1], "expected no correlation without returns, got %v", c.Correlation[0][1])
+
+	var body errorResponse
+
+	status = get(t, router, "/v1/trades/compare?tickers=PETR4,B0GU5", &body)
+	assert.Equal(t, status, http.StatusNotFound, "expected status to be %v, got %v", http.StatusNotFound, status)
+	assert.Equal(t, body.Error.Code, codeNotFound, "expected a not found error, got %v", body)
+
+	status = get(t, router, "/v1/trades/compare", &body)
+	assert.Equal(t, status, http.StatusBadRequest, "expected status to be %v, got %v", http.StatusBadRequest, status)
+	assert.Equal(t, body.Error.Code, codeInvalidArgument, "expected an invalid argument error, got %v", body)
+}
+
+func TestRankings(t *testing.T) {
+	router := newTestRouter(
+		t,
+		db.Trade{Ticker: "PETR4", GrossAmount: 37.5, Quantity: 100, EntryTime: sessionTime(t, 0, 10, 0), TradeID: 1},
+		db.Trade{Ticker: "PETR4", GrossAmount: 38, Quantity: 900, EntryTime: sessionTime(t, 1, 10, 0), TradeID: 1},
+		db.Trade{Ticker: "VALE3", GrossAmount: 60, Quantity: 300, EntryTime: sessionTime(t, 0, 10, 0), TradeID: 1},
+		db.Trade{Ticker: "ITUB4", GrossAmount: 33, Quantity: 200, EntryTime: sessionTime(t, 0, 10, 0), TradeID: 1},
+	)
+
+	var r ranking
+
+	status := get(t, router, "/v1/rankings?date=2024-07-01&limit=2", &r)
+	assert.Equal(t, status, http.StatusOK, "expected status to be %v, got %v", http.StatusOK, status)
+	assert.Equal(t, r.Metric, db.RankVolume, "expected volume to be the default metric, got %v", r.Metric)
+	assert.Equal(t, r.Top, []db.Rank{{Ticker: "VALE3", Value: 300}, {Ticker: "ITUB4", Value: 200}}, "expected the highest volumes of the day first, got %v", r.Top)
+	assert.Equal(t, r.Bottom, []db.Rank{{Ticker: "PETR4", Value: 100}}, "expected the lowest volumes of the day not in top, got %v", r.Bottom)
+
+	r = ranking{}
+
+	status = get(t, router, "/v1/rankings?date=2024-07-01&limit=3", &r)
+	assert.Equal(t, status, http.StatusOK, "expected status to be %v, got %v", http.StatusOK, status)
+	assert.Equal(t, len(r.Top), 3, "expected every ticker in top, got %v", r.Top)
+	assert.Equal(t, r.Bottom, []db.Rank{}, "expected no ticker of top in bottom, got %v", r.Bottom)
+
+	r = ranking{}
+
+	status = get(t, router, "/v1/rankings?from=2024-07-01&metric=trades&limit=1", &r)
+	assert.Equal(t, status, http.StatusOK, "expected status to be %v, got %v", http.StatusOK, status)
+	assert.Equal(t, r.Top, []db.Rank{{Ticker: "PETR4", Value: 2}}, "expected the most trades of the range first, got %v", r.Top)
+
+	for _, path := range []string{
+		"/v1/rankings?metric=spread",
+		"/v1/rankings?limit=1000",
+	} {
+		var body errorResponse
+
+		status := get(t, router, path, &body)
+		assert.Equal(t, status, http.StatusBadRequest, "expected status of %s to be %v, got %v", path, http.StatusBadRequest, status)
+		assert.Equal(t, body.Error.Code, codeInvalidArgument, "expected an invalid argument error for %s, got %v", path, body)
+	}
+}
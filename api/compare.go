@@ -0,0 +1,207 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/eu-ovictor/b3-market-data/db"
+	"github.com/gofiber/fiber/v2"
+)
+
+// maxCompareTickers is the most tickers /trades/compare accepts at once.
+const maxCompareTickers = 20
+
+// comparison is the body of /trades/compare. The series are aligned with
+// Dates, the union of the days any of the tickers traded, and Correlation
+// has a row and a column for each series, in order.
+type comparison struct {
+	Dates       []string     `json:"dates"`
+	Series      []series     `json:"series"`
+	Correlation [][]*float64 `json:"correlation"`
+}
+
+// series is the daily series of a ticker in a comparison. Days it did not
+// trade are null, in Days and in Returns.
+type series struct {
+	Ticker string         `json:"ticker"`
+	Days   []*db.TradeDay `json:"days"`
+	// Returns are the changes of the close from the previous day the
+	// ticker traded, null on its first day.
+	Returns []*float64 `json:"returns"`
+	// TotalReturn is the change of the close from the first to the last
+	// day of the series.
+	TotalReturn *float64 `json:"total_return"`
+}
+
+// compareTickers reads the tickers query parameter of /trades/compare, a
+// comma separated list, leaving out repeated tickers.
+func compareTickers(c *fiber.Ctx) ([]string, error) {
+	tickers := []string{}
+	seen := map[string]bool{}
+
+	for _, ticker := range strings.Split(c.Query("tickers"), ",") {
+		ticker = strings.TrimSpace(ticker)
+		if ticker == "" || seen[ticker] {
+			continue
+		}
+
+		seen[ticker] = true
+		tickers = append(tickers, ticker)
+	}
+
+	if len(tickers) == 0 || len(tickers) > maxCompareTickers {
+		return nil, fmt.Errorf("%w: tickers %q, expected from 1 to %d comma separated tickers", db.ErrInvalidArgument, c.Query("tickers"), maxCompareTickers)
+	}
+
+	return tickers, nil
+}
+
+// compare aligns the days of the tickers, computing their returns and the
+// correlation of the returns of each pair.
+func compare(tickers []string, days map[string][]db.TradeDay) comparison {
+	dates := []string{}
+	seen := map[string]bool{}
+
+	for _, ticker := range tickers {
+		for _, day := range days[ticker] {
+			if !seen[day.Date] {
+				seen[day.Date] = true
+				dates = append(dates, day.Date)
+			}
+		}
+	}
+
+	sort.Strings(dates)
+
+	index := make(map[string]int, len(dates))
+	for i, date := range dates {
+		index[date] = i
+	}
+
+	c := comparison{Dates: dates, Series: make([]series, len(tickers))}
+
+	for i, ticker := range tickers {
+		s := series{
+			Ticker:  ticker,
+			Days:    make([]*db.TradeDay, len(dates)),
+			Returns: make([]*float64, len(dates)),
+		}
+
+		var first, last *float64
+
+		for _, day := range days[ticker] {
+			s.Days[index[day.Date]] = &day
+
+			if day.Close == nil {
+				continue
+			}
+
+			if last != nil && *last != 0 {
+				s.Returns[index[day.Date]] = ptr(*day.Close / *last - 1)
+			}
+
+			if first == nil {
+				first = day.Close
+			}
+
+			last = day.Close
+		}
+
+		if first != nil && *first != 0 {
+			s.TotalReturn = ptr(*last / *first - 1)
+		}
+
+		c.Series[i] = s
+	}
+
+	c.Correlation = make([][]*float64, len(tickers))
+
+	for i := range c.Series {
+		c.Correlation[i] = make([]*float64, len(tickers))
+
+		for j := range c.Series {
+			c.Correlation[i][j] = correlation(c.Series[i].Returns, c.Series[j].Returns)
+		}
+	}
+
+	return c
+}
+
+// correlation is the Pearson correlation of the returns of two series on the
+// days both have one, or nil when there are less than two such days or
+// either series does not vary.
+func correlation(a []*float64, b []*float64) *float64 {
+	var xs, ys []float64
+
+	for i := range a {
+		if a[i] != nil && b[i] != nil {
+			xs = append(xs, *a[i])
+			ys = append(ys, *b[i])
+		}
+	}
+
+	if len(xs) < 2 {
+		return nil
+	}
+
+	var meanX, meanY float64
+
+	for i := range xs {
+		meanX += xs[i]
+		meanY += ys[i]
+	}
+
+	meanX /= float64(len(xs))
+	meanY /= float64(len(ys))
+
+	var cov, varX, varY float64
+
+	for i := range xs {
+		cov += (xs[i] - meanX) * (ys[i] - meanY)
+		varX += (xs[i] - meanX) * (xs[i] - meanX)
+		varY += (ys[i] - meanY) * (ys[i] - meanY)
+	}
+
+	if varX == 0 || varY == 0 {
+		return nil
+	}
+
+	return ptr(cov / math.Sqrt(varX*varY))
+}
+
+func ptr[T any](v T) *T { return &v }
+
+func (app *api) compareHandler(c *fiber.Ctx) error {
+	tickers, err := compareTickers(c)
+	if err != nil {
+		return handleError(c, err)
+	}
+
+	from, to, err := dateRange(c)
+	if err != nil {
+		return handleError(c, err)
+	}
+
+	days, err := app.db.FetchTradeDays(c.UserContext(), tickers, from, to)
+	if err != nil {
+		return handleError(c, err)
+	}
+
+	for _, ticker := range tickers {
+		if len(days[ticker]) == 0 {
+			return handleError(c, fmt.Errorf("%w: no trades for %s", db.ErrNotFound, ticker))
+		}
+	}
+
+	responseBody, err := json.Marshal(compare(tickers, days))
+	if err != nil {
+		return handleError(c, err)
+	}
+
+	c.Set("Content-Type", "application/json")
+
+	return c.Send(responseBody)
+}
@@ -0,0 +1,70 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/eu-ovictor/b3-market-data/db"
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	codeInvalidArgument = "invalid_argument"
+	codeNotFound        = "not_found"
+	codeInternal        = "internal"
+	codeUnavailable     = "unavailable"
+)
+
+// errorResponse is the body of every error response.
+type errorResponse struct {
+	Error errorDetail `json:"error"`
+}
+
+type errorDetail struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// sendError responds with status and a JSON body carrying code and message.
+func sendError(c *fiber.Ctx, status int, code string, message string) error {
+	responseBody, err := json.Marshal(errorResponse{Error: errorDetail{Code: code, Message: message}})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return c.SendStatus(http.StatusInternalServerError)
+	}
+
+	c.Set("Content-Type", "application/json")
+
+	return c.Status(status).Send(responseBody)
+}
+
+// handleError maps the typed errors of db to 400 and 404 responses. Any other
+// error is logged and answered with a 500, without its details.
+func handleError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, db.ErrInvalidArgument):
+		return sendError(c, http.StatusBadRequest, codeInvalidArgument, err.Error())
+	case errors.Is(err, db.ErrNotFound):
+		return sendError(c, http.StatusNotFound, codeNotFound, err.Error())
+	}
+
+	fmt.Fprintln(os.Stderr, err)
+
+	return sendError(c, http.StatusInternalServerError, codeInternal, "internal server error")
+}
+
+// errorHandler answers the errors raised by fiber itself, such as unknown
+// routes, in the same format as the handlers.
+func errorHandler(c *fiber.Ctx, err error) error {
+	var e *fiber.Error
+	if errors.As(err, &e) {
+		code := strings.ReplaceAll(strings.ToLower(http.StatusText(e.Code)), " ", "_")
+		return sendError(c, e.Code, code, e.Message)
+	}
+
+	return handleError(c, err)
+}
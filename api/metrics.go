@@ -0,0 +1,111 @@
+package api
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/adaptor"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// poolStater is implemented by the databases backed by a pgx pool.
+type poolStater interface {
+	Stat() *pgxpool.Stat
+}
+
+// poolCollector exports the statistics of a pgx pool.
+type poolCollector struct {
+	pool poolStater
+}
+
+var (
+	poolAcquiredConns = prometheus.NewDesc("b3_db_pool_acquired_conns", "Connections currently acquired from the pool.", nil, nil)
+	poolIdleConns     = prometheus.NewDesc("b3_db_pool_idle_conns", "Idle connections in the pool.", nil, nil)
+	poolTotalConns    = prometheus.NewDesc("b3_db_pool_total_conns", "Connections in the pool.", nil, nil)
+	poolMaxConns      = prometheus.NewDesc("b3_db_pool_max_conns", "Maximum size of the pool.", nil, nil)
+	poolAcquires      = prometheus.NewDesc("b3_db_pool_acquires_total", "Connections acquired from the pool.", nil, nil)
+	poolEmptyAcquires = prometheus.NewDesc("b3_db_pool_empty_acquires_total", "Acquires that waited for a connection since the pool was empty.", nil, nil)
+	poolAcquireTime   = prometheus.NewDesc("b3_db_pool_acquire_seconds_total", "Time spent acquiring connections from the pool.", nil, nil)
+)
+
+func (c poolCollector) Describe(ch chan<- *prometheus.Desc) {
+	prometheus.DescribeByCollect(c, ch)
+}
+
+func (c poolCollector) Collect(ch chan<- prometheus.Metric) {
+	s := c.pool.Stat()
+
+	ch <- prometheus.MustNewConstMetric(poolAcquiredConns, prometheus.GaugeValue, float64(s.AcquiredConns()))
+	ch <- prometheus.MustNewConstMetric(poolIdleConns, prometheus.GaugeValue, float64(s.IdleConns()))
+	ch <- prometheus.MustNewConstMetric(poolTotalConns, prometheus.GaugeValue, float64(s.TotalConns()))
+	ch <- prometheus.MustNewConstMetric(poolMaxConns, prometheus.GaugeValue, float64(s.MaxConns()))
+	ch <- prometheus.MustNewConstMetric(poolAcquires, prometheus.CounterValue, float64(s.AcquireCount()))
+	ch <- prometheus.MustNewConstMetric(poolEmptyAcquires, prometheus.CounterValue, float64(s.EmptyAcquireCount()))
+	ch <- prometheus.MustNewConstMetric(poolAcquireTime, prometheus.CounterValue, s.AcquireDuration().Seconds())
+}
+
+// metrics keeps the metrics of a router. Each router has its own registry,
+// gathered along with the default one, where the Go runtime and loader
+// metrics are.
+type metrics struct {
+	registry *prometheus.Registry
+	requests *prometheus.HistogramVec
+}
+
+func newMetrics(db any) metrics {
+	m := metrics{
+		registry: prometheus.NewRegistry(),
+		requests: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "b3_http_request_duration_seconds",
+			Help:    "Latency of HTTP requests, by method, route and status.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"method", "route", "status"}),
+	}
+
+	m.registry.MustRegister(m.requests)
+
+	if pool, ok := db.(poolStater); ok {
+		m.registry.MustRegister(poolCollector{pool: pool})
+	}
+
+	return m
+}
+
+// middleware measures requests by the route they matched, so paths with
+// parameters, such as a ticker, are measured together.
+func (m metrics) middleware(c *fiber.Ctx) error {
+	start := time.Now()
+
+	err := c.Next()
+
+	status := c.Response().StatusCode()
+	route := c.Route().Path
+
+	// errors are only turned into responses by the error handler, later
+	if err != nil {
+		status = fiber.StatusInternalServerError
+
+		var e *fiber.Error
+		if errors.As(err, &e) {
+			status = e.Code
+		}
+
+		if status == fiber.StatusNotFound {
+			route = "unmatched"
+		}
+	}
+
+	m.requests.WithLabelValues(c.Method(), route, strconv.Itoa(status)).Observe(time.Since(start).Seconds())
+
+	return err
+}
+
+func (m metrics) handler() fiber.Handler {
+	gatherers := prometheus.Gatherers{m.registry, prometheus.DefaultGatherer}
+
+	return adaptor.HTTPHandler(promhttp.HandlerFor(gatherers, promhttp.HandlerOpts{}))
+}
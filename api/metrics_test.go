@@ -0,0 +1,65 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/eu-ovictor/b3-market-data/db"
+	"github.com/stretchr/testify/assert"
+)
+
+// unreadyDB is a DB whose summaries are not created yet.
+type unreadyDB struct {
+	*db.Memory
+}
+
+func (unreadyDB) Ready(context.Context) error { return errors.New("trade_summary does not exist") }
+
+func TestHealth(t *testing.T) {
+	router := newTestRouter(t)
+
+	for _, path := range []string{"/healthz", "/readyz"} {
+		status := get(t, router, path, nil)
+		assert.Equal(t, status, http.StatusOK, "expected status of %s to be %v, got %v", path, http.StatusOK, status)
+	}
+
+	router = newRouter(unreadyDB{db.NewMemory()})
+
+	status := get(t, router, "/healthz", nil)
+	assert.Equal(t, status, http.StatusOK, "expected status to be %v, got %v", http.StatusOK, status)
+
+	var body errorResponse
+
+	status = get(t, router, "/readyz", &body)
+	assert.Equal(t, status, http.StatusServiceUnavailable, "expected status to be %v, got %v", http.StatusServiceUnavailable, status)
+	assert.Equal(t, body.Error.Code, codeUnavailable, "expected an unavailable error, got %v", body)
+	assert.Equal(t, body.Error.Message, "database not ready", "expected the cause to be left out of the error, got %v", body)
+}
+
+func TestMetrics(t *testing.T) {
+	router := newTestRouter(t)
+
+	get(t, router, "/v1/trades", nil)
+	get(t, router, "/v1/trades/PETR4", nil)
+	get(t, router, "/unknown", nil)
+
+	resp, err := router.Test(httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.NoError(t, err, "expected no error requesting metrics, got %s", err)
+	defer resp.Body.Close()
+
+	b, err := io.ReadAll(resp.Body)
+	assert.NoError(t, err, "expected no error reading metrics, got %s", err)
+
+	for _, metric := range []string{
+		`b3_http_request_duration_seconds_count{method="GET",route="/v1/trades",status="200"} 1`,
+		`b3_http_request_duration_seconds_count{method="GET",route="/v1/trades/:ticker",status="404"} 1`,
+		`b3_http_request_duration_seconds_count{method="GET",route="unmatched",status="404"} 1`,
+		`go_goroutines`,
+	} {
+		assert.Contains(t, string(b), metric, "expected metrics to contain %s", metric)
+	}
+}
@@ -0,0 +1,33 @@
+package api
+
+import (
+	_ "embed"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// openAPI documents every route under the /v1 prefix. It is maintained by
+// hand along with the routes: TestOpenAPI checks that every route is
+// documented, and TestOpenAPIParameters that every documented query parameter
+// is read.
+//
+//go:embed openapi.json
+var openAPI []byte
+
+// docs renders openAPI in the browser. It loads nothing but openapi.json, so
+// it works without access to the internet.
+//
+//go:embed docs.html
+var docs []byte
+
+func openAPIHandler(c *fiber.Ctx) error {
+	c.Set("Content-Type", "application/json")
+
+	return c.Send(openAPI)
+}
+
+func docsHandler(c *fiber.Ctx) error {
+	c.Set("Content-Type", "text/html; charset=utf-8")
+
+	return c.Send(docs)
+}
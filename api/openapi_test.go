@@ -0,0 +1,226 @@
+package api
+
+import (
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/eu-ovictor/b3-market-data/db"
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOpenAPI(t *testing.T) {
+	router := newTestRouter(t)
+
+	var spec struct {
+		OpenAPI string                    `json:"openapi"`
+		Paths   map[string]map[string]any `json:"paths"`
+	}
+
+	status := get(t, router, "/openapi.json", &spec)
+	assert.Equal(t, status, http.StatusOK, "expected status to be %v, got %v", http.StatusOK, status)
+	assert.True(t, strings.HasPrefix(spec.OpenAPI, "3."), "expected an OpenAPI 3 document, got %s", spec.OpenAPI)
+
+	param := regexp.MustCompile(`:(\w+)`)
+	routes := map[string]bool{}
+
+	for _, r := range router.GetRoutes(true) {
+		path, ok := strings.CutPrefix(r.Path, "/v1")
+		if !ok || r.Method != http.MethodGet {
+			continue
+		}
+
+		path = param.ReplaceAllString(path, "{$1}")
+		routes[path] = true
+
+		_, ok = spec.Paths[path]["get"]
+		assert.True(t, ok, "expected GET %s to be documented", path)
+	}
+
+	for path := range spec.Paths {
+		assert.True(t, routes[path], "expected documented path %s to be served under /v1", path)
+	}
+
+	status = get(t, router, "/docs", nil)
+	assert.Equal(t, status, http.StatusOK, "expected status to be %v, got %v", http.StatusOK, status)
+	assert.NotRegexp(t, `(src|href)="(https?:)?//`, string(docs), "expected /docs not to load assets from other hosts")
+}
+
+// TestOpenAPIParameters sends, for every query parameter documented in
+// openapi.json, a request with an invalid value, expecting the route to read
+// and reject it. The table has to list exactly the documented parameters.
+func TestOpenAPIParameters(t *testing.T) {
+	router := newTestRouter(
+		t,
+		db.Trade{Ticker: "PETR4", GrossAmount: 37.5, Quantity: 100, EntryTime: sessionTime(t, 0, 10, 0), TradeID: 1},
+	)
+
+	cases := []struct {
+		path    string
+		request string
+		invalid map[string]string
+	}{
+		{
+			path:    "/trades",
+			request: "/v1/trades",
+			invalid: map[string]string{
+				"from":   "2024-13-01",
+				"to":     "2024-13-01",
+				"date":   "2024-13-01",
+				"fields": "nope",
+				"ticker": "PETR%25",
+				"sort":   "nope",
+				"limit":  "0",
+				"cursor": "nope",
+			},
+		},
+		{
+			path:    "/trades/compare",
+			request: "/v1/trades/compare",
+			invalid: map[string]string{
+				"tickers": ",",
+				"from":    "2024-13-01",
+				"to":      "2024-13-01",
+				"date":    "2024-13-01",
+			},
+		},
+		{
+			path:    "/trades/{ticker}",
+			request: "/v1/trades/PETR4",
+			invalid: map[string]string{
+				"from":   "2024-13-01",
+				"to":     "2024-13-01",
+				"date":   "2024-13-01",
+				"fields": "nope",
+			},
+		},
+		{
+			path:    "/trades/{ticker}/daily",
+			request: "/v1/trades/PETR4/daily",
+			invalid: map[string]string{
+				"from": "2024-13-01",
+				"to":   "2024-13-01",
+				"date": "2024-13-01",
+			},
+		},
+		{
+			path:    "/trades/{ticker}/candles",
+			request: "/v1/trades/PETR4/candles",
+			invalid: map[string]string{
+				"interval": "nope",
+				"from":     "2024-13-01",
+				"to":       "2024-13-01",
+				"date":     "2024-13-01",
+			},
+		},
+		{
+			path:    "/trades/{ticker}/ticks",
+			request: "/v1/trades/PETR4/ticks",
+			invalid: map[string]string{
+				"date":      "2024-13-01",
+				"from_time": "25:00",
+				"to_time":   "25:00",
+				"format":    "nope",
+			},
+		},
+		{
+			path:    "/rankings",
+			request: "/v1/rankings",
+			invalid: map[string]string{
+				"date":   "2024-13-01",
+				"from":   "2024-13-01",
+				"to":     "2024-13-01",
+				"metric": "nope",
+				"limit":  "0",
+			},
+		},
+	}
+
+	documented := documentedQueries(t, router)
+	tested := map[string]bool{}
+
+	for _, c := range cases {
+		tested[c.path] = true
+
+		names := []string{}
+		for name := range c.invalid {
+			names = append(names, name)
+		}
+
+		assert.ElementsMatch(t, names, documented[c.path], "expected the parameters tested for %s to be the documented ones", c.path)
+
+		// the parameters required by some routes, valid
+		valid := url.Values{"date": {"2024-07-01"}, "tickers": {"PETR4"}}
+
+		status := get(t, router, c.request+"?"+valid.Encode(), nil)
+		assert.Equal(t, status, http.StatusOK, "expected status of %s to be %v, got %v", c.request, http.StatusOK, status)
+
+		for name, value := range c.invalid {
+			query := url.Values{"date": {"2024-07-01"}, "tickers": {"PETR4"}}
+			query.Set(name, value)
+
+			path := c.request + "?" + query.Encode()
+
+			var body errorResponse
+
+			status := get(t, router, path, &body)
+			assert.Equal(t, status, http.StatusBadRequest, "expected status of %s to be %v, got %v", path, http.StatusBadRequest, status)
+			assert.Equal(t, body.Error.Code, codeInvalidArgument, "expected an invalid argument error for %s, got %v", path, body)
+		}
+	}
+
+	for path := range documented {
+		assert.True(t, tested[path], "expected the parameters of %s to be tested", path)
+	}
+}
+
+// documentedQueries returns the names of the query parameters documented for
+// each GET path of openapi.json.
+func documentedQueries(t *testing.T, router *fiber.App) map[string][]string {
+	t.Helper()
+
+	type parameter struct {
+		Ref  string `json:"$ref"`
+		Name string `json:"name"`
+		In   string `json:"in"`
+	}
+
+	var spec struct {
+		Paths map[string]map[string]struct {
+			Parameters []parameter `json:"parameters"`
+		} `json:"paths"`
+		Components struct {
+			Parameters map[string]parameter `json:"parameters"`
+		} `json:"components"`
+	}
+
+	get(t, router, "/openapi.json", &spec)
+
+	documented := map[string][]string{}
+
+	for path, methods := range spec.Paths {
+		for _, p := range methods["get"].Parameters {
+			if ref, ok := strings.CutPrefix(p.Ref, "#/components/parameters/"); ok {
+				p = spec.Components.Parameters[ref]
+			}
+
+			if p.In == "query" {
+				documented[path] = append(documented[path], p.Name)
+			}
+		}
+	}
+
+	return documented
+}
+
+func TestV1Prefix(t *testing.T) {
+	router := newTestRouter(t)
+
+	for _, path := range []string{"/v1/trades", "/trades"} {
+		status := get(t, router, path, nil)
+		assert.Equal(t, status, http.StatusOK, "expected status of %s to be %v, got %v", path, http.StatusOK, status)
+	}
+}
@@ -0,0 +1,98 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/eu-ovictor/b3-market-data/db"
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	defaultRankingLimit = 10
+	maxRankingLimit     = 100
+)
+
+// ranking is the body of /rankings, with the tickers of the highest and
+// lowest values of the metric, each from the extreme inwards. A ticker is in
+// one of them at most, so bottom is shorter than top when there are fewer
+// than twice limit tickers.
+type ranking struct {
+	Metric string    `json:"metric"`
+	Top    []db.Rank `json:"top"`
+	Bottom []db.Rank `json:"bottom"`
+}
+
+// rankingQuery reads the period, metric and limit of /rankings. Unlike the
+// other routes, date alone selects that single day.
+func rankingQuery(c *fiber.Ctx) (db.RankingQuery, error) {
+	from, to, err := dateRange(c)
+	if err != nil {
+		return db.RankingQuery{}, err
+	}
+
+	if c.Query("from") == "" && c.Query("to") == "" {
+		to = from
+	}
+
+	q := db.RankingQuery{
+		From:   from,
+		To:     to,
+		Metric: c.Query("metric", db.RankVolume),
+		Limit:  defaultRankingLimit,
+	}
+
+	if limit := c.Query("limit"); limit != "" {
+		q.Limit, err = strconv.Atoi(limit)
+		if err != nil || q.Limit < 1 || q.Limit > maxRankingLimit {
+			return db.RankingQuery{}, fmt.Errorf("%w: limit %q, expected a number from 1 to %d", db.ErrInvalidArgument, limit, maxRankingLimit)
+		}
+	}
+
+	return q, nil
+}
+
+func (app *api) rankingsHandler(c *fiber.Ctx) error {
+	q, err := rankingQuery(c)
+	if err != nil {
+		return handleError(c, err)
+	}
+
+	r := ranking{Metric: q.Metric}
+
+	q.Desc = true
+
+	if r.Top, err = app.db.FetchRanking(c.UserContext(), q); err != nil {
+		return handleError(c, err)
+	}
+
+	q.Desc = false
+
+	bottom, err := app.db.FetchRanking(c.UserContext(), q)
+	if err != nil {
+		return handleError(c, err)
+	}
+
+	// with fewer than twice limit tickers, the lowest would repeat the top
+	top := map[string]bool{}
+	for _, rank := range r.Top {
+		top[rank.Ticker] = true
+	}
+
+	r.Bottom = bottom[:0]
+	for _, rank := range bottom {
+		if !top[rank.Ticker] {
+			r.Bottom = append(r.Bottom, rank)
+		}
+	}
+
+	responseBody, err := json.Marshal(r)
+	if err != nil {
+		return handleError(c, err)
+	}
+
+	c.Set("Content-Type", "application/json")
+
+	return c.Send(responseBody)
+}
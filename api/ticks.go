@@ -0,0 +1,153 @@
+package api
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/eu-ovictor/b3-market-data/db"
+	"github.com/gofiber/fiber/v2"
+)
+
+// tickEncoder writes ticks in one of the formats of /ticks. Flush writes the
+// ticks it buffers itself, if any, to the underlying writer.
+type tickEncoder interface {
+	Encode(db.Tick) error
+	Flush() error
+}
+
+// tickFormats are the formats of /ticks, by their name in the format query
+// parameter.
+var tickFormats = map[string]struct {
+	contentType string
+	encoder     func(*bufio.Writer) (tickEncoder, error)
+}{
+	"ndjson": {"application/x-ndjson", newNDJSONEncoder},
+	"csv":    {"text/csv", newCSVEncoder},
+}
+
+// ndjsonEncoder writes a JSON object per line.
+type ndjsonEncoder struct {
+	enc *json.Encoder
+}
+
+func newNDJSONEncoder(w *bufio.Writer) (tickEncoder, error) {
+	return ndjsonEncoder{json.NewEncoder(w)}, nil
+}
+
+func (e ndjsonEncoder) Encode(tick db.Tick) error { return e.enc.Encode(tick) }
+
+func (ndjsonEncoder) Flush() error { return nil }
+
+// csvEncoder writes a header and a row per tick.
+type csvEncoder struct {
+	w *csv.Writer
+}
+
+func newCSVEncoder(w *bufio.Writer) (tickEncoder, error) {
+	e := csvEncoder{csv.NewWriter(w)}
+
+	if err := e.w.Write([]string{"time", "price", "quantity", "trade_id"}); err != nil {
+		return nil, err
+	}
+
+	return e, nil
+}
+
+func (e csvEncoder) Encode(tick db.Tick) error {
+	return e.w.Write([]string{
+		tick.Time.Format(time.RFC3339Nano),
+		strconv.FormatFloat(tick.Price, 'f', -1, 64),
+		strconv.FormatInt(tick.Quantity, 10),
+		strconv.FormatInt(tick.TradeID, 10),
+	})
+}
+
+func (e csvEncoder) Flush() error {
+	e.w.Flush()
+
+	return e.w.Error()
+}
+
+// tickFlushEvery is how many ticks are written between flushes, so clients
+// receive a long session as it is read.
+const tickFlushEvery = 1000
+
+// getTicksHandler streams the trades of a session with chunked transfer, so
+// they are never held in memory at once. Since the status is sent before the
+// first trade is read, errors while streaming can only cut the body short,
+// and are logged.
+func (app *api) getTicksHandler(c *fiber.Ctx) error {
+	format := c.Query("format", "ndjson")
+
+	tf, ok := tickFormats[format]
+	if !ok {
+		return handleError(c, fmt.Errorf("%w: format %q, expected ndjson or csv", db.ErrInvalidArgument, format))
+	}
+
+	rows, err := app.db.GetTicks(c.UserContext(), db.TickQuery{
+		Ticker:   c.Params("ticker"),
+		Date:     c.Query("date"),
+		FromTime: c.Query("from_time"),
+		ToTime:   c.Query("to_time"),
+	})
+	if err != nil {
+		return handleError(c, err)
+	}
+
+	c.Set("Content-Type", tf.contentType)
+
+	// HEAD responses have no body, so the stream writer would never run
+	if c.Method() == fiber.MethodHead {
+		rows.Close()
+		return nil
+	}
+
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		defer rows.Close()
+
+		if err := streamTicks(w, tf.encoder, rows); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+		}
+	})
+
+	return nil
+}
+
+// streamTicks writes rows to w, flushing every tickFlushEvery ticks.
+func streamTicks(w *bufio.Writer, encoder func(*bufio.Writer) (tickEncoder, error), rows db.TickRows) error {
+	enc, err := encoder(w)
+	if err != nil {
+		return err
+	}
+
+	flush := func() error {
+		if err := enc.Flush(); err != nil {
+			return err
+		}
+
+		return w.Flush()
+	}
+
+	for n := 1; rows.Next(); n++ {
+		if err := enc.Encode(rows.Tick()); err != nil {
+			return err
+		}
+
+		if n%tickFlushEvery == 0 {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	return flush()
+}
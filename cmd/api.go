@@ -16,7 +16,7 @@ var port string
 var apiCmd = &cobra.Command{
 	Use:   "api",
 	Short: "Spins up the web API",
-	RunE: func(_ *cobra.Command, _ []string) error {
+	RunE: func(c *cobra.Command, _ []string) error {
 		u, err := loadDatabaseURI()
 		if err != nil {
 			return err
@@ -30,15 +30,13 @@ var apiCmd = &cobra.Command{
 			port = defaultPort
 		}
 
-		pg, err := db.NewPostgreSQL(u)
+		conn, err := db.Open(c.Context(), u)
 		if err != nil {
 			return err
 		}
-		defer pg.Close()
+		defer conn.Close()
 
-		api.Serve(&pg, port)
-
-		return nil
+		return api.Serve(c.Context(), conn, port)
 	},
 }
 
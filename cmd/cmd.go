@@ -28,7 +28,7 @@ func addDataDir(c *cobra.Command) *cobra.Command {
 }
 
 func addDatabase(c *cobra.Command) *cobra.Command {
-	c.Flags().StringVarP(&databaseURI, "database-uri", "u", "", "PostgreSQL URI (default DATABASE_URL environment variable)")
+	c.Flags().StringVarP(&databaseURI, "database-uri", "u", "", "database URI, postgres:// or a key=value connection string for PostgreSQL, or sqlite:///path for a SQLite file (default DATABASE_URL environment variable)")
 	return c
 }
 
@@ -54,7 +54,7 @@ func loadDatabaseURI() (string, error) {
 	u := os.Getenv("DATABASE_URL")
 
 	if u == "" {
-		return "", fmt.Errorf("could not find a database URI, pass it as a flag or set DATABASE_URL environment variable with the credentials for a PostgreSQL database")
+		return "", fmt.Errorf("could not find a database URI, pass it as a flag or set DATABASE_URL environment variable with the credentials for a PostgreSQL database or the sqlite:// URI of a SQLite file")
 	}
 
 	return u, nil
@@ -68,10 +68,12 @@ var rootCmd = &cobra.Command{
 
 // CLI returns the root command from Cobra CLI tool.
 func CLI() *cobra.Command {
-	for _, c := range []*cobra.Command{apiCLI(), loadCLI()} {
+	for _, c := range []*cobra.Command{apiCLI(), loadCLI(), refreshCLI(), compressionCLI(), retentionCLI()} {
 		addDatabase(c)
 		rootCmd.AddCommand(c)
 	}
 
+	rootCmd.AddCommand(migrateCLI())
+
 	return rootCmd
 }
@@ -1,17 +1,27 @@
 package cmd
 
 import (
+	"fmt"
+
 	"github.com/eu-ovictor/b3-market-data/db"
 	"github.com/eu-ovictor/b3-market-data/loader"
 	"github.com/spf13/cobra"
 )
 
-var batchSize int
+var (
+	batchSize         int
+	force             bool
+	mode              string
+	workers           int
+	insertConcurrency int
+	loadCompressAfter string
+	loadRetention     string
+)
 
 var loadCmd = &cobra.Command{
 	Use:   "load",
 	Short: "Loads downloaded B3 market data into database.",
-	RunE: func(_ *cobra.Command, _ []string) error {
+	RunE: func(c *cobra.Command, _ []string) error {
 		if err := assertDirExists(); err != nil {
 			return err
 		}
@@ -21,30 +31,65 @@ var loadCmd = &cobra.Command{
 			return err
 		}
 
-		pg, err := db.NewPostgreSQL(u)
+		conn, err := db.Open(c.Context(), u)
 		if err != nil {
 			return err
 		}
-		defer pg.Close()
+		defer conn.Close()
 
-		if err := pg.CreateTable(); err != nil {
+		if err := conn.CreateTable(c.Context()); err != nil {
 			return err
 		}
 
-		if err := loader.Load(dir, batchSize, &pg); err != nil {
+		opts := loader.Options{
+			BatchSize:         batchSize,
+			Force:             force,
+			Mode:              mode,
+			Workers:           workers,
+			InsertConcurrency: insertConcurrency,
+		}
+
+		if err := loader.Load(c.Context(), dir, conn, opts); err != nil {
 			return err
 		}
 
-		if err := pg.PostLoad(); err != nil {
+		if err := conn.PostLoad(c.Context()); err != nil {
 			return err
 		}
 
+		if loadCompressAfter == "" && loadRetention == "" {
+			return nil
+		}
+
+		p, ok := conn.(db.Policies)
+		if !ok {
+			return fmt.Errorf("database does not support compression and retention policies")
+		}
+
+		if loadCompressAfter != "" {
+			if err := p.SetCompression(c.Context(), loadCompressAfter); err != nil {
+				return err
+			}
+		}
+
+		if loadRetention != "" {
+			if err := p.SetRetention(c.Context(), loadRetention); err != nil {
+				return err
+			}
+		}
+
 		return nil
 	},
 }
 
 func loadCLI() *cobra.Command {
 	loadCmd = addDataDir(loadCmd)
-	loadCmd.Flags().IntVarP(&batchSize, "batch-size", "b", 1000, "max length of rows inserted at once")
+	loadCmd.Flags().IntVarP(&batchSize, "batch-size", "b", 1000, "max length of rows inserted at once in batch mode")
+	loadCmd.Flags().StringVarP(&mode, "mode", "m", loader.ModeCopy, fmt.Sprintf("how trades are inserted, %s streams each file through COPY and %s uses batches of INSERT statements", loader.ModeCopy, loader.ModeBatch))
+	loadCmd.Flags().IntVarP(&workers, "workers", "w", 2, "number of files read at the same time (and of concurrent copies in copy mode)")
+	loadCmd.Flags().IntVarP(&insertConcurrency, "insert-concurrency", "c", 4, "number of batches inserted at the same time in batch mode")
+	loadCmd.Flags().StringVar(&loadCompressAfter, "compress-after", "", fmt.Sprintf("compress trades older than a PostgreSQL interval, such as %q (default unchanged)", defaultCompressAfter))
+	loadCmd.Flags().StringVar(&loadRetention, "retention", "", fmt.Sprintf("drop trades older than a PostgreSQL interval, such as %q (default unchanged)", defaultRetention))
+	loadCmd.Flags().BoolVarP(&force, "force", "f", false, "reload files already loaded according to the load manifest")
 	return loadCmd
 }
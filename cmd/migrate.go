@@ -0,0 +1,122 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/eu-ovictor/b3-market-data/db"
+	"github.com/spf13/cobra"
+)
+
+var steps int
+
+// openMigrator connects to the database, which must support migrations.
+func openMigrator(c *cobra.Command) (db.DB, db.Migrator, error) {
+	u, err := loadDatabaseURI()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	conn, err := db.Open(c.Context(), u)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	m, ok := conn.(db.Migrator)
+	if !ok {
+		conn.Close()
+		return nil, nil, fmt.Errorf("database does not support migrations")
+	}
+
+	return conn, m, nil
+}
+
+var migrateUpCmd = &cobra.Command{
+	Use:   "up",
+	Short: "Applies every pending migration",
+	RunE: func(c *cobra.Command, _ []string) error {
+		conn, m, err := openMigrator(c)
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+
+		n, err := m.MigrateUp(c.Context())
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintf(os.Stderr, "%d migrations applied\n", n)
+
+		return nil
+	},
+}
+
+var migrateDownCmd = &cobra.Command{
+	Use:   "down",
+	Short: "Reverts the last applied migrations",
+	RunE: func(c *cobra.Command, _ []string) error {
+		conn, m, err := openMigrator(c)
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+
+		n, err := m.MigrateDown(c.Context(), steps)
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintf(os.Stderr, "%d migrations reverted\n", n)
+
+		return nil
+	},
+}
+
+var migrateStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Lists migrations and whether they are applied",
+	RunE: func(c *cobra.Command, _ []string) error {
+		conn, m, err := openMigrator(c)
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+
+		status, err := m.MigrationStatus(c.Context())
+		if err != nil {
+			return err
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED AT")
+
+		for _, s := range status {
+			appliedAt := "pending"
+			if s.Applied() {
+				appliedAt = s.AppliedAt.Format(time.RFC3339)
+			}
+
+			fmt.Fprintf(w, "%d\t%s\t%s\n", s.Version, s.Name, appliedAt)
+		}
+
+		return w.Flush()
+	},
+}
+
+var migrateCmd = &cobra.Command{
+	Use:   "migrate <command>",
+	Short: "Manages the versioned database schema",
+}
+
+func migrateCLI() *cobra.Command {
+	migrateDownCmd.Flags().IntVarP(&steps, "steps", "n", 1, "number of migrations reverted")
+
+	for _, c := range []*cobra.Command{migrateUpCmd, migrateDownCmd, migrateStatusCmd} {
+		migrateCmd.AddCommand(addDatabase(c))
+	}
+
+	return migrateCmd
+}
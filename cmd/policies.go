@@ -0,0 +1,85 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/eu-ovictor/b3-market-data/db"
+	"github.com/spf13/cobra"
+)
+
+const (
+	defaultCompressAfter = "7 days"
+	defaultRetention     = "1 year"
+)
+
+var (
+	compressAfter      string
+	disableCompression bool
+	retention          string
+	disableRetention   bool
+)
+
+// withPolicies connects to the database, which must support compression and
+// retention policies, and calls f with it.
+func withPolicies(c *cobra.Command, f func(context.Context, db.Policies) error) error {
+	u, err := loadDatabaseURI()
+	if err != nil {
+		return err
+	}
+
+	conn, err := db.Open(c.Context(), u)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	p, ok := conn.(db.Policies)
+	if !ok {
+		return fmt.Errorf("database does not support compression and retention policies")
+	}
+
+	return f(c.Context(), p)
+}
+
+var compressionCmd = &cobra.Command{
+	Use:   "compression",
+	Short: "Configures the compression of old trades.",
+	RunE: func(c *cobra.Command, _ []string) error {
+		after := compressAfter
+		if disableCompression {
+			after = ""
+		}
+
+		return withPolicies(c, func(ctx context.Context, p db.Policies) error {
+			return p.SetCompression(ctx, after)
+		})
+	},
+}
+
+var retentionCmd = &cobra.Command{
+	Use:   "retention",
+	Short: "Configures how long trades are kept, daily summaries are kept forever.",
+	RunE: func(c *cobra.Command, _ []string) error {
+		keep := retention
+		if disableRetention {
+			keep = ""
+		}
+
+		return withPolicies(c, func(ctx context.Context, p db.Policies) error {
+			return p.SetRetention(ctx, keep)
+		})
+	},
+}
+
+func compressionCLI() *cobra.Command {
+	compressionCmd.Flags().StringVar(&compressAfter, "after", defaultCompressAfter, "age of the trades compressed, as a PostgreSQL interval")
+	compressionCmd.Flags().BoolVar(&disableCompression, "disable", false, "stop compressing trades")
+	return compressionCmd
+}
+
+func retentionCLI() *cobra.Command {
+	retentionCmd.Flags().StringVar(&retention, "keep", defaultRetention, "age of the trades dropped, as a PostgreSQL interval")
+	retentionCmd.Flags().BoolVar(&disableRetention, "disable", false, "keep trades forever")
+	return retentionCmd
+}
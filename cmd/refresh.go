@@ -0,0 +1,36 @@
+package cmd
+
+import (
+	"github.com/eu-ovictor/b3-market-data/db"
+	"github.com/spf13/cobra"
+)
+
+var (
+	refreshFrom string
+	refreshTo   string
+)
+
+var refreshCmd = &cobra.Command{
+	Use:   "refresh",
+	Short: "Refreshes the daily summaries served by the API.",
+	RunE: func(c *cobra.Command, _ []string) error {
+		u, err := loadDatabaseURI()
+		if err != nil {
+			return err
+		}
+
+		conn, err := db.Open(c.Context(), u)
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+
+		return conn.Refresh(c.Context(), refreshFrom, refreshTo)
+	},
+}
+
+func refreshCLI() *cobra.Command {
+	refreshCmd.Flags().StringVar(&refreshFrom, "from", "", "first day refreshed, as YYYY-MM-DD (default every day before --to)")
+	refreshCmd.Flags().StringVar(&refreshTo, "to", "", "last day refreshed, as YYYY-MM-DD (default every day after --from)")
+	return refreshCmd
+}
@@ -0,0 +1,21 @@
+package db
+
+import "time"
+
+// CandleIntervals maps the intervals accepted for candles to PostgreSQL intervals.
+var CandleIntervals = map[string]string{
+	"1m": "1 minute",
+	"5m": "5 minutes",
+	"1h": "1 hour",
+	"1d": "1 day",
+}
+
+type Candle struct {
+	Time       time.Time `json:"time"`
+	Open       float64   `json:"open"`
+	High       float64   `json:"high"`
+	Low        float64   `json:"low"`
+	Close      float64   `json:"close"`
+	Volume     int64     `json:"volume"`
+	TradeCount int64     `json:"trade_count"`
+}
@@ -0,0 +1,872 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// testDB runs the suite every DB implementation must pass. open returns an
+// empty DB whose tables are already created.
+func testDB(t *testing.T, open func(t *testing.T) DB) {
+	for _, c := range []struct {
+		name string
+		test func(*testing.T, DB)
+	}{
+		{"Summaries", testSummaries},
+		{"SummariesByDate", testSummariesByDate},
+		{"SummaryStats", testSummaryStats},
+		{"GetTradeDays", testGetTradeDays},
+		{"FetchTradeDays", testFetchTradeDays},
+		{"FetchRanking", testFetchRanking},
+		{"GetTradeWithoutTrades", testGetTradeWithoutTrades},
+		{"InvalidArguments", testInvalidArguments},
+		{"FetchTradesPages", testFetchTradesPages},
+		{"GetCandles", testGetCandles},
+		{"GetTicks", testGetTicks},
+		{"CancelMany", testCancelMany},
+		{"StoringAgainUncancels", testStoringAgainUncancels},
+		{"InsertManyIsIdempotent", testInsertManyIsIdempotent},
+		{"TradeReplacedInSession", testTradeReplacedInSession},
+		{"Manifest", testManifest},
+		{"CopyMany", testCopyMany},
+		{"Refresh", testRefresh},
+		{"Ready", testReady},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			c.test(t, open(t))
+		})
+	}
+}
+
+// day returns the given time of day on the B3 session of 2024-07-01 plus
+// offset days.
+func day(t *testing.T, offset int, hour int, minute int) time.Time {
+	t.Helper()
+
+	loc, err := loadLocation()
+	if err != nil {
+		t.Fatalf("expected no error loading location, got %s", err)
+	}
+
+	return time.Date(2024, 7, 1+offset, hour, minute, 0, 0, loc)
+}
+
+func testSummaries(t *testing.T, db DB) {
+	trades := []Trade{
+		{Ticker: TICKER, GrossAmount: 1, Quantity: 20, EntryTime: day(t, 0, 10, 0), TradeID: 1},
+		{Ticker: TICKER, GrossAmount: 2, Quantity: 15, EntryTime: day(t, 1, 10, 0), TradeID: 1},
+		{Ticker: TICKER, GrossAmount: 1.5, Quantity: 15, EntryTime: day(t, 1, 11, 0), TradeID: 2},
+		{Ticker: ANOTHER_TICKER, GrossAmount: 10, Quantity: 5, EntryTime: day(t, 0, 10, 0), TradeID: 1},
+	}
+
+	err := db.InsertMany(context.Background(), trades)
+	assert.NoError(t, err, "expected no error inserting trades, got %s", err)
+
+	err = db.PostLoad(context.Background())
+	assert.NoError(t, err, "expected no error building summaries, got %s", err)
+
+	page, err := db.FetchTrades(context.Background(), TradeQuery{})
+	summaries := page.Trades
+	assert.NoError(t, err, "expected no error fetching trades, got %s", err)
+	assert.Equal(t, len(summaries), 2, "expected a summary by ticker, got %v", summaries)
+
+	summary, err := db.GetTrade(context.Background(), TICKER, "", "")
+	assert.NoError(t, err, "expected no error getting summary, got %s", err)
+
+	assert.Equal(t, summary.Ticker, TICKER, "expected summary ticker to be %v, got %v", TICKER, summary.Ticker)
+	assert.Equal(t, summary.MaxRangeValue, 2.0, "expected max range value to be %v, got %v", 2.0, summary.MaxRangeValue)
+	assert.Equal(t, summary.MaxDailyVolume, int64(30), "expected max daily volume to be %v, got %v", 30, summary.MaxDailyVolume)
+}
+
+func testSummariesByDate(t *testing.T, db DB) {
+	trades := []Trade{
+		{Ticker: TICKER, GrossAmount: 3, Quantity: 50, EntryTime: day(t, 0, 17, 50), TradeID: 1},
+		{Ticker: TICKER, GrossAmount: 1, Quantity: 20, EntryTime: day(t, 1, 10, 0), TradeID: 1},
+		{Ticker: ANOTHER_TICKER, GrossAmount: 10, Quantity: 5, EntryTime: day(t, 0, 10, 0), TradeID: 1},
+	}
+
+	err := db.InsertMany(context.Background(), trades)
+	assert.NoError(t, err, "expected no error inserting trades, got %s", err)
+
+	err = db.PostLoad(context.Background())
+	assert.NoError(t, err, "expected no error building summaries, got %s", err)
+
+	page, err := db.FetchTrades(context.Background(), TradeQuery{From: "2024-07-02"})
+	summaries := page.Trades
+	assert.NoError(t, err, "expected no error fetching trades, got %s", err)
+	assert.Equal(t, len(summaries), 1, "expected a single summary, got %v", summaries)
+
+	summary, err := db.GetTrade(context.Background(), TICKER, "2024-07-02", "")
+	assert.NoError(t, err, "expected no error getting summary, got %s", err)
+
+	assert.Equal(t, summary.MaxRangeValue, 1.0, "expected max range value to be %v, got %v", 1.0, summary.MaxRangeValue)
+	assert.Equal(t, summary.MaxDailyVolume, int64(20), "expected max daily volume to be %v, got %v", 20, summary.MaxDailyVolume)
+
+	page, err = db.FetchTrades(context.Background(), TradeQuery{To: "2024-07-01"})
+	summaries = page.Trades
+	assert.NoError(t, err, "expected no error fetching trades, got %s", err)
+	assert.Equal(t, len(summaries), 2, "expected a summary by ticker, got %v", summaries)
+
+	summary, err = db.GetTrade(context.Background(), TICKER, "2024-07-01", "2024-07-01")
+	assert.NoError(t, err, "expected no error getting summary, got %s", err)
+
+	assert.Equal(t, summary.MaxRangeValue, 3.0, "expected max range value to be %v, got %v", 3.0, summary.MaxRangeValue)
+	assert.Equal(t, summary.MaxDailyVolume, int64(50), "expected max daily volume to be %v, got %v", 50, summary.MaxDailyVolume)
+}
+
+func testSummaryStats(t *testing.T, db DB) {
+	// trades are inserted out of time order, so open and close do not
+	// depend on the order of insertion
+	trades := []Trade{
+		{Ticker: TICKER, GrossAmount: 1.5, Quantity: 15, EntryTime: day(t, 1, 11, 0), TradeID: 2},
+		{Ticker: TICKER, GrossAmount: 3, Quantity: 10, EntryTime: day(t, 0, 11, 0), TradeID: 2},
+		{Ticker: TICKER, GrossAmount: 1, Quantity: 20, EntryTime: day(t, 0, 10, 0), TradeID: 1},
+		{Ticker: TICKER, GrossAmount: 2, Quantity: 15, EntryTime: day(t, 1, 10, 0), TradeID: 1},
+	}
+
+	err := db.InsertMany(context.Background(), trades)
+	assert.NoError(t, err, "expected no error inserting trades, got %s", err)
+
+	err = db.PostLoad(context.Background())
+	assert.NoError(t, err, "expected no error building summaries, got %s", err)
+
+	summary, err := db.GetTrade(context.Background(), TICKER, "", "")
+	assert.NoError(t, err, "expected no error getting summary, got %s", err)
+
+	assertStats(t, summary, TradeSummary{
+		Open:            ptr(1.0),
+		Close:           ptr(1.5),
+		MinRangeValue:   ptr(1.0),
+		VWAP:            ptr(102.5 / 60),
+		Volume:          ptr(int64(60)),
+		FinancialVolume: ptr(102.5),
+		TradeCount:      ptr(int64(4)),
+		AvgTradeSize:    ptr(15.0),
+	})
+
+	page, err := db.FetchTrades(context.Background(), TradeQuery{From: "2024-07-02"})
+	assert.NoError(t, err, "expected no error fetching trades, got %s", err)
+	assert.Equal(t, len(page.Trades), 1, "expected a single summary, got %v", page.Trades)
+
+	assertStats(t, page.Trades[0], TradeSummary{
+		Open:            ptr(2.0),
+		Close:           ptr(1.5),
+		MinRangeValue:   ptr(1.5),
+		VWAP:            ptr(1.75),
+		Volume:          ptr(int64(30)),
+		FinancialVolume: ptr(52.5),
+		TradeCount:      ptr(int64(2)),
+		AvgTradeSize:    ptr(15.0),
+	})
+}
+
+// assertStats compares the statistics of a summary, allowing for the
+// rounding of prices averaged by the database.
+func assertStats(t *testing.T, got TradeSummary, want TradeSummary) {
+	t.Helper()
+
+	for _, f := range []struct {
+		name string
+		got  *float64
+		want *float64
+	}{
+		{"open", got.Open, want.Open},
+		{"close", got.Close, want.Close},
+		{"min range value", got.MinRangeValue, want.MinRangeValue},
+		{"vwap", got.VWAP, want.VWAP},
+		{"financial volume", got.FinancialVolume, want.FinancialVolume},
+		{"average trade size", got.AvgTradeSize, want.AvgTradeSize},
+	} {
+		if assert.NotNil(t, f.got, "expected %s to be set", f.name) {
+			assert.InDelta(t, *f.want, *f.got, 1e-9, "expected %s to be %v, got %v", f.name, *f.want, *f.got)
+		}
+	}
+
+	assert.Equal(t, want.Volume, got.Volume, "expected volume to be %v, got %v", want.Volume, got.Volume)
+	assert.Equal(t, want.TradeCount, got.TradeCount, "expected trade count to be %v, got %v", want.TradeCount, got.TradeCount)
+}
+
+func testGetTradeDays(t *testing.T, db DB) {
+	trades := []Trade{
+		{Ticker: TICKER, GrossAmount: 3, Quantity: 10, EntryTime: day(t, 0, 11, 0), TradeID: 2},
+		{Ticker: TICKER, GrossAmount: 1, Quantity: 20, EntryTime: day(t, 0, 10, 0), TradeID: 1},
+		{Ticker: TICKER, GrossAmount: 2, Quantity: 15, EntryTime: day(t, 2, 10, 0), TradeID: 1},
+		{Ticker: ANOTHER_TICKER, GrossAmount: 10, Quantity: 5, EntryTime: day(t, 1, 10, 0), TradeID: 1},
+	}
+
+	err := db.InsertMany(context.Background(), trades)
+	assert.NoError(t, err, "expected no error inserting trades, got %s", err)
+
+	err = db.PostLoad(context.Background())
+	assert.NoError(t, err, "expected no error building summaries, got %s", err)
+
+	days, err := db.GetTradeDays(context.Background(), TICKER, "", "")
+	assert.NoError(t, err, "expected no error getting days, got %s", err)
+	assert.Equal(t, len(days), 2, "expected a day for each session with trades, got %v", days)
+
+	first := days[0]
+	assert.Equal(t, first.Date, "2024-07-01", "expected the first day to be %v, got %v", "2024-07-01", first.Date)
+	assert.Equal(t, first.MaxRangeValue, 3.0, "expected max range value to be %v, got %v", 3.0, first.MaxRangeValue)
+	assert.Equal(t, first.Volume, int64(30), "expected volume to be %v, got %v", 30, first.Volume)
+	assertStats(t, TradeSummary{
+		Open:            first.Open,
+		Close:           first.Close,
+		MinRangeValue:   first.MinRangeValue,
+		VWAP:            first.VWAP,
+		Volume:          &first.Volume,
+		FinancialVolume: first.FinancialVolume,
+		TradeCount:      first.TradeCount,
+		AvgTradeSize:    first.AvgTradeSize,
+	}, TradeSummary{
+		Open:            ptr(1.0),
+		Close:           ptr(3.0),
+		MinRangeValue:   ptr(1.0),
+		VWAP:            ptr(50.0 / 30),
+		Volume:          ptr(int64(30)),
+		FinancialVolume: ptr(50.0),
+		TradeCount:      ptr(int64(2)),
+		AvgTradeSize:    ptr(15.0),
+	})
+
+	days, err = db.GetTradeDays(context.Background(), TICKER, "2024-07-02", "2024-07-03")
+	assert.NoError(t, err, "expected no error getting days, got %s", err)
+	assert.Equal(t, len(days), 1, "expected a single day, got %v", days)
+	assert.Equal(t, days[0].Date, "2024-07-03", "expected the day to be %v, got %v", "2024-07-03", days[0].Date)
+
+	days, err = db.GetTradeDays(context.Background(), TICKER, "2024-07-04", "")
+	assert.NoError(t, err, "expected no error getting days, got %s", err)
+	assert.Equal(t, len(days), 0, "expected no days without trades, got %v", days)
+
+	_, err = db.GetTradeDays(context.Background(), TICKER, "07/01/2024", "")
+	assert.ErrorIs(t, err, ErrInvalidArgument, "expected an invalid argument error, got %s", err)
+}
+
+func testFetchTradeDays(t *testing.T, db DB) {
+	trades := []Trade{
+		{Ticker: TICKER, GrossAmount: 1, Quantity: 20, EntryTime: day(t, 0, 10, 0), TradeID: 1},
+		{Ticker: TICKER, GrossAmount: 2, Quantity: 15, EntryTime: day(t, 1, 10, 0), TradeID: 1},
+		{Ticker: ANOTHER_TICKER, GrossAmount: 10, Quantity: 5, EntryTime: day(t, 1, 10, 0), TradeID: 1},
+		{Ticker: "L3FT0UT", GrossAmount: 5, Quantity: 5, EntryTime: day(t, 1, 10, 0), TradeID: 1},
+	}
+
+	err := db.InsertMany(context.Background(), trades)
+	assert.NoError(t, err, "expected no error inserting trades, got %s", err)
+
+	err = db.PostLoad(context.Background())
+	assert.NoError(t, err, "expected no error building summaries, got %s", err)
+
+	days, err := db.FetchTradeDays(context.Background(), []string{TICKER, ANOTHER_TICKER, "N0TRAD3S"}, "", "")
+	assert.NoError(t, err, "expected no error fetching days, got %s", err)
+	assert.Equal(t, len(days), 2, "expected the days of the tickers with trades, got %v", days)
+	assert.Equal(t, len(days[TICKER]), 2, "expected a day for each session of %s, got %v", TICKER, days[TICKER])
+	assert.Equal(t, days[TICKER][1].Date, "2024-07-02", "expected days in date order, got %v", days[TICKER])
+	assert.Equal(t, days[ANOTHER_TICKER][0].Volume, int64(5), "expected volume to be %v, got %v", 5, days[ANOTHER_TICKER][0].Volume)
+
+	days, err = db.FetchTradeDays(context.Background(), []string{TICKER, ANOTHER_TICKER}, "2024-07-01", "2024-07-01")
+	assert.NoError(t, err, "expected no error fetching days, got %s", err)
+	assert.Equal(t, len(days), 1, "expected only the tickers with trades in the range, got %v", days)
+
+	_, err = db.FetchTradeDays(context.Background(), nil, "", "")
+	assert.ErrorIs(t, err, ErrInvalidArgument, "expected an invalid argument error, got %s", err)
+}
+
+func testFetchRanking(t *testing.T, db DB) {
+	trades := []Trade{
+		{Ticker: TICKER, GrossAmount: 10, Quantity: 100, EntryTime: day(t, 0, 10, 0), TradeID: 1},
+		{Ticker: TICKER, GrossAmount: 12, Quantity: 100, EntryTime: day(t, 1, 10, 0), TradeID: 1},
+		{Ticker: ANOTHER_TICKER, GrossAmount: 20, Quantity: 30, EntryTime: day(t, 0, 10, 0), TradeID: 1},
+		{Ticker: ANOTHER_TICKER, GrossAmount: 18, Quantity: 30, EntryTime: day(t, 0, 11, 0), TradeID: 2},
+		{Ticker: "TH1RD", GrossAmount: 5, Quantity: 500, EntryTime: day(t, 1, 10, 0), TradeID: 1},
+	}
+
+	err := db.InsertMany(context.Background(), trades)
+	assert.NoError(t, err, "expected no error inserting trades, got %s", err)
+
+	err = db.PostLoad(context.Background())
+	assert.NoError(t, err, "expected no error building summaries, got %s", err)
+
+	for _, c := range []struct {
+		query RankingQuery
+		want  []Rank
+	}{
+		{
+			RankingQuery{Metric: RankVolume, Desc: true, Limit: 2},
+			[]Rank{{"TH1RD", 500}, {TICKER, 200}},
+		},
+		{
+			RankingQuery{Metric: RankFinancialVolume, Limit: 1},
+			[]Rank{{ANOTHER_TICKER, 1140}},
+		},
+		{
+			RankingQuery{Metric: RankTrades, Desc: true, Limit: 3},
+			[]Rank{{ANOTHER_TICKER, 2}, {TICKER, 2}, {"TH1RD", 1}},
+		},
+		{
+			RankingQuery{Metric: RankReturn, Desc: true, Limit: 3},
+			[]Rank{{TICKER, 0.2}, {"TH1RD", 0}, {ANOTHER_TICKER, -0.1}},
+		},
+		{
+			RankingQuery{From: "2024-07-01", To: "2024-07-01", Metric: RankVolume, Limit: 10},
+			[]Rank{{ANOTHER_TICKER, 60}, {TICKER, 100}},
+		},
+	} {
+		ranks, err := db.FetchRanking(context.Background(), c.query)
+		assert.NoError(t, err, "expected no error ranking %v, got %s", c.query, err)
+
+		if assert.Equal(t, len(ranks), len(c.want), "expected %v for %v, got %v", c.want, c.query, ranks) {
+			for i := range ranks {
+				assert.Equal(t, ranks[i].Ticker, c.want[i].Ticker, "expected %v for %v, got %v", c.want, c.query, ranks)
+				assert.InDelta(t, ranks[i].Value, c.want[i].Value, 1e-9, "expected %v for %v, got %v", c.want, c.query, ranks)
+			}
+		}
+	}
+
+	for _, q := range []RankingQuery{
+		{Metric: "spread", Limit: 10},
+		{Metric: RankVolume},
+		{Metric: RankVolume, Limit: 10, From: "2024-07-32"},
+	} {
+		_, err := db.FetchRanking(context.Background(), q)
+		assert.ErrorIs(t, err, ErrInvalidArgument, "expected an invalid argument error for %v, got %s", q, err)
+	}
+}
+
+func testGetTradeWithoutTrades(t *testing.T, db DB) {
+	err := db.PostLoad(context.Background())
+	assert.NoError(t, err, "expected no error building summaries, got %s", err)
+
+	_, err = db.GetTrade(context.Background(), TICKER, "", "")
+	assert.ErrorIs(t, err, ErrNotFound, "expected a not found error, got %s", err)
+}
+
+func testInvalidArguments(t *testing.T, db DB) {
+	_, err := db.FetchTrades(context.Background(), TradeQuery{From: "2024-07-32"})
+	assert.ErrorIs(t, err, ErrInvalidArgument, "expected an invalid argument error, got %s", err)
+
+	_, err = db.GetTrade(context.Background(), TICKER, "", "july")
+	assert.ErrorIs(t, err, ErrInvalidArgument, "expected an invalid argument error, got %s", err)
+
+	_, err = db.GetCandles(context.Background(), TICKER, "2m", "", "")
+	assert.ErrorIs(t, err, ErrInvalidArgument, "expected an invalid argument error, got %s", err)
+
+	_, err = db.GetCandles(context.Background(), TICKER, "1m", "01/07/2024", "")
+	assert.ErrorIs(t, err, ErrInvalidArgument, "expected an invalid argument error, got %s", err)
+}
+
+func testGetCandles(t *testing.T, db DB) {
+	now := day(t, 0, 10, 0)
+
+	trades := []Trade{
+		{
+			Ticker:      TICKER,
+			GrossAmount: 2,
+			Quantity:    10,
+			EntryTime:   now.Add(-2 * time.Second),
+		},
+		{
+			Ticker:      TICKER,
+			GrossAmount: 3,
+			Quantity:    20,
+			EntryTime:   now.Add(-1 * time.Second),
+		},
+		{
+			Ticker:      TICKER,
+			GrossAmount: 1,
+			Quantity:    30,
+			EntryTime:   now,
+		},
+		{
+			Ticker:      ANOTHER_TICKER,
+			GrossAmount: 10,
+			Quantity:    10,
+			EntryTime:   now,
+		},
+	}
+
+	err := db.InsertMany(context.Background(), trades)
+	assert.NoError(t, err, "expected no error inserting trades, got %s", err)
+
+	err = db.PostLoad(context.Background())
+	assert.NoError(t, err, "expected no error building summaries, got %s", err)
+
+	candles, err := db.GetCandles(context.Background(), TICKER, "1d", "", "")
+	assert.NoError(t, err, "expected no error getting candles, got %s", err)
+	assert.Equal(t, len(candles), 1, "expected a single daily candle, got %v", candles)
+
+	candle := candles[0]
+	assert.True(t, candle.Time.Equal(day(t, 0, 0, 0)), "expected candle to start at %v, got %v", day(t, 0, 0, 0), candle.Time)
+	assert.Equal(t, candle.Open, 2.0, "expected open to be %v, got %v", 2.0, candle.Open)
+	assert.Equal(t, candle.High, 3.0, "expected high to be %v, got %v", 3.0, candle.High)
+	assert.Equal(t, candle.Low, 1.0, "expected low to be %v, got %v", 1.0, candle.Low)
+	assert.Equal(t, candle.Close, 1.0, "expected close to be %v, got %v", 1.0, candle.Close)
+	assert.Equal(t, candle.Volume, int64(60), "expected volume to be %v, got %v", 60, candle.Volume)
+	assert.Equal(t, candle.TradeCount, int64(3), "expected trade count to be %v, got %v", 3, candle.TradeCount)
+
+	candles, err = db.GetCandles(context.Background(), TICKER, "1m", "2024-07-01", "2024-07-01")
+	assert.NoError(t, err, "expected no error getting candles, got %s", err)
+	assert.Equal(t, len(candles), 2, "expected a candle for each minute, got %v", candles)
+	assert.True(t, candles[1].Time.Equal(now), "expected last candle to start at %v, got %v", now, candles[1].Time)
+	assert.Equal(t, candles[1].Open, 1.0, "expected open of the last candle to be %v, got %v", 1.0, candles[1].Open)
+
+	candles, err = db.GetCandles(context.Background(), TICKER, "1m", "2024-07-02", "")
+	assert.NoError(t, err, "expected no error getting candles, got %s", err)
+	assert.Equal(t, len(candles), 0, "expected no candles after the trades, got %v", candles)
+}
+
+// readTicks reads every tick of a query.
+func readTicks(t *testing.T, db DB, q TickQuery) []Tick {
+	t.Helper()
+
+	rows, err := db.GetTicks(context.Background(), q)
+	if err != nil {
+		t.Fatalf("expected no error getting ticks, got %s", err)
+	}
+	defer rows.Close()
+
+	ticks := []Tick{}
+
+	for rows.Next() {
+		ticks = append(ticks, rows.Tick())
+	}
+
+	if err := rows.Err(); err != nil {
+		t.Fatalf("expected no error reading ticks, got %s", err)
+	}
+
+	return ticks
+}
+
+func testGetTicks(t *testing.T, db DB) {
+	trades := []Trade{
+		{Ticker: TICKER, GrossAmount: 2, Quantity: 20, EntryTime: day(t, 0, 10, 0), TradeID: 2},
+		{Ticker: TICKER, GrossAmount: 1, Quantity: 10, EntryTime: day(t, 0, 9, 30), TradeID: 1},
+		{Ticker: TICKER, GrossAmount: 3, Quantity: 30, EntryTime: day(t, 0, 11, 0), TradeID: 3},
+		{Ticker: TICKER, GrossAmount: 4, Quantity: 40, EntryTime: day(t, 1, 10, 0), TradeID: 1},
+		{Ticker: ANOTHER_TICKER, GrossAmount: 10, Quantity: 5, EntryTime: day(t, 0, 10, 0), TradeID: 1},
+	}
+
+	err := db.InsertMany(context.Background(), trades)
+	assert.NoError(t, err, "expected no error inserting trades, got %s", err)
+
+	ticks := readTicks(t, db, TickQuery{Ticker: TICKER, Date: "2024-07-01"})
+	assert.Equal(t, len(ticks), 3, "expected the trades of the session, got %v", ticks)
+	assert.True(t, ticks[0].Time.Equal(day(t, 0, 9, 30)), "expected the first tick at %v, got %v", day(t, 0, 9, 30), ticks[0].Time)
+	assert.Equal(t, ticks[0].Price, 1.0, "expected the first tick price to be %v, got %v", 1.0, ticks[0].Price)
+	assert.Equal(t, ticks[2].TradeID, int64(3), "expected the last tick to be trade %v, got %v", 3, ticks[2].TradeID)
+
+	ticks = readTicks(t, db, TickQuery{Ticker: TICKER, Date: "2024-07-01", FromTime: "10:00", ToTime: "11:00:00"})
+	assert.Equal(t, len(ticks), 1, "expected from_time to be inclusive and to_time exclusive, got %v", ticks)
+	assert.Equal(t, ticks[0].Quantity, int64(20), "expected the tick quantity to be %v, got %v", 20, ticks[0].Quantity)
+
+	err = db.CancelMany(context.Background(), []Trade{{Ticker: TICKER, EntryTime: day(t, 0, 17, 0), TradeID: 2}})
+	assert.NoError(t, err, "expected no error cancelling trades, got %s", err)
+
+	ticks = readTicks(t, db, TickQuery{Ticker: TICKER, Date: "2024-07-01", FromTime: "10:00"})
+	assert.Equal(t, len(ticks), 1, "expected cancelled trades to be left out, got %v", ticks)
+
+	for _, q := range []TickQuery{
+		{Ticker: TICKER},
+		{Ticker: TICKER, Date: "2024-07-01", FromTime: "25:00"},
+		{Ticker: TICKER, Date: "2024-07-01", FromTime: "12:00", ToTime: "11:00"},
+	} {
+		_, err := db.GetTicks(context.Background(), q)
+		assert.ErrorIs(t, err, ErrInvalidArgument, "expected an invalid argument error for %v, got %s", q, err)
+	}
+}
+
+func testCancelMany(t *testing.T, db DB) {
+	cancelledTrade := Trade{
+		Ticker:      TICKER,
+		GrossAmount: 2,
+		Quantity:    20,
+		EntryTime:   time.Now(),
+		TradeID:     2,
+	}
+
+	expectedTrade := Trade{
+		Ticker:      TICKER,
+		GrossAmount: 1,
+		Quantity:    10,
+		EntryTime:   time.Now(),
+		TradeID:     1,
+	}
+
+	cancellation := cancelledTrade
+	cancellation.UpdateAction = UpdateActionCancel
+
+	err := db.InsertMany(context.Background(), []Trade{cancelledTrade, expectedTrade})
+	assert.NoError(t, err, "expected no error inserting trades, got %s", err)
+
+	err = db.CancelMany(context.Background(), []Trade{cancellation})
+	assert.NoError(t, err, "expected no error cancelling trades, got %s", err)
+
+	err = db.PostLoad(context.Background())
+	assert.NoError(t, err, "expected no error building summaries, got %s", err)
+
+	summary, err := db.GetTrade(context.Background(), TICKER, "", "")
+	assert.NoError(t, err, "expected no error getting summary, got %s", err)
+
+	assert.Equal(t, summary.MaxRangeValue, expectedTrade.GrossAmount, "expected max range value to be %v, got %v", expectedTrade.GrossAmount, summary.MaxRangeValue)
+	assert.Equal(t, summary.MaxDailyVolume, expectedTrade.Quantity, "expected max daily volume to be %v, got %v", expectedTrade.Quantity, summary.MaxDailyVolume)
+}
+
+// testStoringAgainUncancels checks that a cancelled trade stored again, as a
+// correction at the same or at another time or by loading its file again,
+// is counted again.
+func testStoringAgainUncancels(t *testing.T, db DB) {
+	original := Trade{Ticker: TICKER, GrossAmount: 1, Quantity: 10, EntryTime: day(t, 0, 10, 0), TradeID: 1}
+
+	cancellation := original
+	cancellation.UpdateAction = UpdateActionCancel
+
+	sameTime := original
+	sameTime.GrossAmount, sameTime.Quantity, sameTime.UpdateAction = 2, 20, UpdateActionCorrect
+
+	otherTime := sameTime
+	otherTime.EntryTime = day(t, 0, 11, 0)
+
+	for _, c := range []struct {
+		name   string
+		stored Trade
+	}{
+		{"correction at the same time", sameTime},
+		{"correction at another time", otherTime},
+		{"reload", original},
+	} {
+		err := db.InsertMany(context.Background(), []Trade{original})
+		assert.NoError(t, err, "expected no error inserting the trade, got %s", err)
+
+		err = db.CancelMany(context.Background(), []Trade{cancellation})
+		assert.NoError(t, err, "expected no error cancelling the trade, got %s", err)
+
+		ticks := readTicks(t, db, TickQuery{Ticker: TICKER, Date: "2024-07-01"})
+		assert.Empty(t, ticks, "expected the trade to be cancelled before the %s, got %v", c.name, ticks)
+
+		err = db.InsertMany(context.Background(), []Trade{c.stored})
+		assert.NoError(t, err, "expected no error storing the %s, got %s", c.name, err)
+
+		err = db.PostLoad(context.Background())
+		assert.NoError(t, err, "expected no error building summaries, got %s", err)
+
+		ticks = readTicks(t, db, TickQuery{Ticker: TICKER, Date: "2024-07-01"})
+		assert.Equal(t, ticks, []Tick{{Time: c.stored.EntryTime, Price: c.stored.GrossAmount, Quantity: c.stored.Quantity, TradeID: 1}}, "expected the %s to be counted, got %v", c.name, ticks)
+
+		summary, err := db.GetTrade(context.Background(), TICKER, "", "")
+		assert.NoError(t, err, "expected no error getting summary after the %s, got %s", c.name, err)
+		assert.Equal(t, summary.MaxDailyVolume, c.stored.Quantity, "expected the %s to be summarized, got %v", c.name, summary.MaxDailyVolume)
+
+		// the copy path has the same semantics
+		err = db.CancelMany(context.Background(), []Trade{cancellation})
+		assert.NoError(t, err, "expected no error cancelling the trade, got %s", err)
+
+		_, err = db.CopyMany(context.Background(), &sliceSource{trades: []Trade{c.stored}})
+		assert.NoError(t, err, "expected no error copying the %s, got %s", c.name, err)
+
+		ticks = readTicks(t, db, TickQuery{Ticker: TICKER, Date: "2024-07-01"})
+		assert.Equal(t, len(ticks), 1, "expected the copied %s to be counted, got %v", c.name, ticks)
+
+		// back to the original for the next case
+		_, err = db.CopyMany(context.Background(), &sliceSource{trades: []Trade{original}})
+		assert.NoError(t, err, "expected no error copying the original trade, got %s", err)
+	}
+}
+
+func testInsertManyIsIdempotent(t *testing.T, db DB) {
+	now := time.Now()
+
+	trades := []Trade{
+		{
+			Ticker:      TICKER,
+			GrossAmount: 1,
+			Quantity:    10,
+			EntryTime:   now,
+			TradeID:     1,
+		},
+		{
+			Ticker:      TICKER,
+			GrossAmount: 1,
+			Quantity:    20,
+			EntryTime:   now,
+			TradeID:     2,
+		},
+	}
+
+	var expectedMaxDailyVolume int64 = 30
+
+	var err error
+
+	for range 2 {
+		err = db.InsertMany(context.Background(), trades)
+		assert.NoError(t, err, "expected no error inserting trades, got %s", err)
+	}
+
+	err = db.PostLoad(context.Background())
+	assert.NoError(t, err, "expected no error building summaries, got %s", err)
+
+	summary, err := db.GetTrade(context.Background(), TICKER, "", "")
+	assert.NoError(t, err, "expected no error getting summary, got %s", err)
+
+	assert.Equal(t, summary.MaxDailyVolume, expectedMaxDailyVolume, "expected max daily volume to be %v, got %v", expectedMaxDailyVolume, summary.MaxDailyVolume)
+}
+
+// testTradeReplacedInSession checks the key of trades, their ticker, ID and
+// B3 session: a trade loaded again with the same ID on the same session, at
+// another time, replaces the original. SQLite and Memory key trades by
+// session, while PostgreSQL keys them by entry_time, which the hypertable
+// requires, deleting the trade it replaces first, so this runs both paths
+// of PostgreSQL: DELETE_REPLACED_TRADE for InsertMany and the staging
+// statements for CopyMany.
+func testTradeReplacedInSession(t *testing.T, db DB) {
+	original := Trade{Ticker: TICKER, GrossAmount: 1, Quantity: 10, EntryTime: day(t, 0, 10, 0), TradeID: 1}
+	corrected := Trade{Ticker: TICKER, GrossAmount: 2, Quantity: 30, EntryTime: day(t, 0, 11, 0), TradeID: 1}
+	nextDay := Trade{Ticker: TICKER, GrossAmount: 3, Quantity: 5, EntryTime: day(t, 1, 10, 0), TradeID: 1}
+
+	err := db.InsertMany(context.Background(), []Trade{original, nextDay})
+	assert.NoError(t, err, "expected no error inserting trades, got %s", err)
+
+	err = db.InsertMany(context.Background(), []Trade{corrected})
+	assert.NoError(t, err, "expected no error inserting the corrected trade, got %s", err)
+
+	ticks := readTicks(t, db, TickQuery{Ticker: TICKER, Date: "2024-07-01"})
+	assert.Equal(t, ticks, []Tick{{Time: corrected.EntryTime, Price: 2, Quantity: 30, TradeID: 1}}, "expected the corrected trade to replace the original, got %v", ticks)
+
+	// within a single copy the last trade wins
+	_, err = db.CopyMany(context.Background(), &sliceSource{trades: []Trade{corrected, original}})
+	assert.NoError(t, err, "expected no error copying trades, got %s", err)
+
+	ticks = readTicks(t, db, TickQuery{Ticker: TICKER, Date: "2024-07-01"})
+	assert.Equal(t, ticks, []Tick{{Time: original.EntryTime, Price: 1, Quantity: 10, TradeID: 1}}, "expected the last copied trade to replace the others, got %v", ticks)
+
+	// a copy replaces a trade stored at another time
+	_, err = db.CopyMany(context.Background(), &sliceSource{trades: []Trade{corrected}})
+	assert.NoError(t, err, "expected no error copying the corrected trade, got %s", err)
+
+	ticks = readTicks(t, db, TickQuery{Ticker: TICKER, Date: "2024-07-01"})
+	assert.Equal(t, ticks, []Tick{{Time: corrected.EntryTime, Price: 2, Quantity: 30, TradeID: 1}}, "expected the copied trade to replace the stored one, got %v", ticks)
+
+	// within a single insert the last trade wins as well
+	err = db.InsertMany(context.Background(), []Trade{original, corrected})
+	assert.NoError(t, err, "expected no error inserting trades, got %s", err)
+
+	ticks = readTicks(t, db, TickQuery{Ticker: TICKER, Date: "2024-07-01"})
+	assert.Equal(t, ticks, []Tick{{Time: corrected.EntryTime, Price: 2, Quantity: 30, TradeID: 1}}, "expected the last inserted trade to replace the others, got %v", ticks)
+
+	ticks = readTicks(t, db, TickQuery{Ticker: TICKER, Date: "2024-07-02"})
+	assert.Equal(t, len(ticks), 1, "expected the trade with the same ID on another session to be kept, got %v", ticks)
+}
+
+func testManifest(t *testing.T, db DB) {
+	expectedManifest := Manifest{
+		FileName:      "2024-07-01.zip",
+		SHA256:        "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855",
+		RowCount:      42,
+		ReferenceDate: time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC),
+		Status:        ManifestLoaded,
+		StartedAt:     time.Now().Add(-time.Minute).Truncate(time.Microsecond),
+		FinishedAt:    time.Now().Truncate(time.Microsecond),
+	}
+
+	err := db.PostLoad(context.Background())
+	assert.NoError(t, err, "expected no error building summaries, got %s", err)
+
+	manifest, err := db.GetManifest(context.Background(), expectedManifest.FileName)
+	assert.NoError(t, err, "expected no error getting manifest, got %s", err)
+	assert.Equal(t, manifest.Status, "", "expected a file never loaded to have no status, got %v", manifest.Status)
+
+	err = db.SaveManifest(context.Background(), expectedManifest)
+	assert.NoError(t, err, "expected no error saving manifest, got %s", err)
+
+	manifest, err = db.GetManifest(context.Background(), expectedManifest.FileName)
+	assert.NoError(t, err, "expected no error getting manifest, got %s", err)
+
+	assert.Equal(t, manifest.SHA256, expectedManifest.SHA256, "expected sha256 to be %v, got %v", expectedManifest.SHA256, manifest.SHA256)
+	assert.Equal(t, manifest.RowCount, expectedManifest.RowCount, "expected row count to be %v, got %v", expectedManifest.RowCount, manifest.RowCount)
+	assert.Equal(t, manifest.Status, expectedManifest.Status, "expected status to be %v, got %v", expectedManifest.Status, manifest.Status)
+	assert.True(t, manifest.FinishedAt.Equal(expectedManifest.FinishedAt), "expected finished at to be %v, got %v", expectedManifest.FinishedAt, manifest.FinishedAt)
+}
+
+func testCopyMany(t *testing.T, db DB) {
+	now := time.Now()
+
+	trades := []Trade{
+		{
+			Ticker:      TICKER,
+			GrossAmount: 1,
+			Quantity:    10,
+			EntryTime:   now,
+			TradeID:     1,
+		},
+		{
+			Ticker:      TICKER,
+			GrossAmount: 2,
+			Quantity:    20,
+			EntryTime:   now,
+			TradeID:     2,
+		},
+	}
+
+	var expectedMaxDailyVolume int64 = 30
+
+	var err error
+
+	for range 2 {
+		n, err := db.CopyMany(context.Background(), &sliceSource{trades: trades})
+		assert.NoError(t, err, "expected no error copying trades, got %s", err)
+		assert.Equal(t, n, int64(len(trades)), "expected %v trades copied, got %v", len(trades), n)
+	}
+
+	err = db.PostLoad(context.Background())
+	assert.NoError(t, err, "expected no error building summaries, got %s", err)
+
+	summary, err := db.GetTrade(context.Background(), TICKER, "", "")
+	assert.NoError(t, err, "expected no error getting summary, got %s", err)
+
+	assert.Equal(t, summary.MaxRangeValue, 2.0, "expected max range value to be %v, got %v", 2.0, summary.MaxRangeValue)
+	assert.Equal(t, summary.MaxDailyVolume, expectedMaxDailyVolume, "expected max daily volume to be %v, got %v", expectedMaxDailyVolume, summary.MaxDailyVolume)
+}
+
+func testRefresh(t *testing.T, db DB) {
+	err := db.InsertMany(context.Background(), []Trade{
+		{Ticker: TICKER, GrossAmount: 1, Quantity: 10, EntryTime: day(t, 0, 10, 0), TradeID: 1},
+	})
+	assert.NoError(t, err, "expected no error inserting trades, got %s", err)
+
+	err = db.PostLoad(context.Background())
+	assert.NoError(t, err, "expected no error building summaries, got %s", err)
+
+	err = db.InsertMany(context.Background(), []Trade{
+		{Ticker: TICKER, GrossAmount: 2, Quantity: 20, EntryTime: day(t, 1, 10, 0), TradeID: 1},
+	})
+	assert.NoError(t, err, "expected no error inserting trades, got %s", err)
+
+	err = db.PostLoad(context.Background())
+	assert.NoError(t, err, "expected no error refreshing summaries, got %s", err)
+
+	summary, err := db.GetTrade(context.Background(), TICKER, "", "")
+	assert.NoError(t, err, "expected no error getting summary, got %s", err)
+	assert.Equal(t, summary.MaxRangeValue, 2.0, "expected a second load to be summarized, got %v", summary.MaxRangeValue)
+
+	err = db.CancelMany(context.Background(), []Trade{
+		{Ticker: TICKER, EntryTime: day(t, 1, 10, 0), TradeID: 1},
+	})
+	assert.NoError(t, err, "expected no error cancelling trades, got %s", err)
+
+	err = db.Refresh(context.Background(), "2024-07-02", "2024-07-02")
+	assert.NoError(t, err, "expected no error refreshing a window, got %s", err)
+
+	summary, err = db.GetTrade(context.Background(), TICKER, "", "")
+	assert.NoError(t, err, "expected no error getting summary, got %s", err)
+	assert.Equal(t, summary.MaxRangeValue, 1.0, "expected the cancellation to be summarized, got %v", summary.MaxRangeValue)
+
+	err = db.Refresh(context.Background(), "not a date", "")
+	assert.Error(t, err, "expected an error refreshing with an invalid date")
+}
+
+func testFetchTradesPages(t *testing.T, db DB) {
+	trades := []Trade{
+		{Ticker: "PETR3", GrossAmount: 1, Quantity: 30, EntryTime: day(t, 0, 10, 0), TradeID: 1},
+		{Ticker: "PETR4", GrossAmount: 1, Quantity: 10, EntryTime: day(t, 0, 10, 0), TradeID: 1},
+		{Ticker: "VALE3", GrossAmount: 1, Quantity: 20, EntryTime: day(t, 0, 10, 0), TradeID: 1},
+	}
+
+	err := db.InsertMany(context.Background(), trades)
+	assert.NoError(t, err, "expected no error inserting trades, got %s", err)
+
+	err = db.PostLoad(context.Background())
+	assert.NoError(t, err, "expected no error building summaries, got %s", err)
+
+	tickers := func(page TradePage) []string {
+		tickers := []string{}
+		for _, trade := range page.Trades {
+			tickers = append(tickers, trade.Ticker)
+		}
+		return tickers
+	}
+
+	page, err := db.FetchTrades(context.Background(), TradeQuery{Ticker: "petr*"})
+	assert.NoError(t, err, "expected no error fetching trades, got %s", err)
+	assert.Equal(t, tickers(page), []string{"PETR3", "PETR4"}, "expected the tickers matching petr*, got %v", page)
+
+	q := TradeQuery{Sort: SortMaxDailyVolume, Desc: true, Limit: 2}
+
+	page, err = db.FetchTrades(context.Background(), q)
+	assert.NoError(t, err, "expected no error fetching trades, got %s", err)
+	assert.Equal(t, tickers(page), []string{"PETR3", "VALE3"}, "expected the first page by volume, got %v", page)
+	assert.NotEmpty(t, page.NextCursor, "expected a cursor for the next page")
+
+	q.Cursor = page.NextCursor
+
+	page, err = db.FetchTrades(context.Background(), q)
+	assert.NoError(t, err, "expected no error fetching trades, got %s", err)
+	assert.Equal(t, tickers(page), []string{"PETR4"}, "expected the last page by volume, got %v", page)
+	assert.Empty(t, page.NextCursor, "expected no cursor after the last page, got %s", page.NextCursor)
+
+	// ties on the sort field are paginated by ticker
+	q = TradeQuery{Sort: SortMaxRangeValue, Limit: 1}
+	all := []string{}
+
+	for {
+		page, err = db.FetchTrades(context.Background(), q)
+		assert.NoError(t, err, "expected no error fetching trades, got %s", err)
+
+		all = append(all, tickers(page)...)
+
+		if page.NextCursor == "" || len(all) > len(trades) {
+			break
+		}
+
+		q.Cursor = page.NextCursor
+	}
+
+	assert.Equal(t, all, []string{"PETR3", "PETR4", "VALE3"}, "expected every ticker once, got %v", all)
+
+	_, err = db.FetchTrades(context.Background(), TradeQuery{Sort: SortMaxDailyVolume, Cursor: q.Cursor})
+	assert.ErrorIs(t, err, ErrInvalidArgument, "expected a cursor of another sort to be invalid, got %s", err)
+
+	_, err = db.FetchTrades(context.Background(), TradeQuery{Cursor: "not a cursor"})
+	assert.ErrorIs(t, err, ErrInvalidArgument, "expected an invalid cursor error, got %s", err)
+
+	_, err = db.FetchTrades(context.Background(), TradeQuery{Sort: "quantity"})
+	assert.ErrorIs(t, err, ErrInvalidArgument, "expected an invalid sort error, got %s", err)
+}
+
+func testReady(t *testing.T, db DB) {
+	err := db.Ready(context.Background())
+	assert.NoError(t, err, "expected the database to be ready, got %s", err)
+}
+
+// testMigrations checks a Migrator whose migrations were all applied.
+func testMigrations(t *testing.T, m Migrator) {
+	status, err := m.MigrationStatus(context.Background())
+	assert.NoError(t, err, "expected no error getting migration status, got %s", err)
+
+	for _, s := range status {
+		assert.True(t, s.Applied(), "expected migration %d %s to be applied", s.Version, s.Name)
+	}
+
+	n, err := m.MigrateDown(context.Background(), 1)
+	assert.NoError(t, err, "expected no error reverting a migration, got %s", err)
+	assert.Equal(t, n, 1, "expected a single migration to be reverted, got %d", n)
+
+	status, err = m.MigrationStatus(context.Background())
+	assert.NoError(t, err, "expected no error getting migration status, got %s", err)
+	assert.False(t, status[len(status)-1].Applied(), "expected the last migration to be pending, got %v", status[len(status)-1])
+
+	n, err = m.MigrateUp(context.Background())
+	assert.NoError(t, err, "expected no error applying migrations, got %s", err)
+	assert.Equal(t, n, 1, "expected the reverted migration to be applied again, got %d", n)
+
+	n, err = m.MigrateDown(context.Background(), len(status))
+	assert.NoError(t, err, "expected no error reverting every migration, got %s", err)
+	assert.Equal(t, n, len(status), "expected every migration to be reverted, got %d", n)
+
+	n, err = m.MigrateUp(context.Background())
+	assert.NoError(t, err, "expected no error applying migrations, got %s", err)
+	assert.Equal(t, n, len(status), "expected every migration to be applied again, got %d", n)
+
+	_, err = m.MigrateDown(context.Background(), 0)
+	assert.Error(t, err, "expected an error reverting zero migrations")
+}
@@ -1,7 +1,59 @@
 package db
 
+import "context"
+
 type DB interface {
-	InsertMany([]Trade) error
-	FetchTrades(string) ([]TradeSummary, error)
-	GetTrade(string, string) (TradeSummary, error)
+	// CreateTable prepares the storage for trades before a load.
+	CreateTable(context.Context) error
+	// PostLoad builds the summaries served by the API after a load.
+	PostLoad(context.Context) error
+	// Refresh rebuilds the summaries of the days from one date to another,
+	// inclusive. Empty dates leave the window open.
+	Refresh(context.Context, string, string) error
+	Close()
+	// Ready checks the database is reachable and has the summaries served
+	// by the API.
+	Ready(context.Context) error
+
+	// InsertMany and CopyMany store trades by their key, the ticker, trade
+	// ID and B3 session: a trade stored again replaces the one with the same
+	// key, even when entered at another time, and is no longer cancelled.
+	// PostgreSQL cannot have such a unique index, since every unique index of
+	// the trade hypertable must include entry_time, so it deletes the trade
+	// replaced before the upsert. The conformance suite checks the backends
+	// agree.
+	InsertMany(context.Context, []Trade) error
+	CopyMany(context.Context, TradeSource) (int64, error)
+	CancelMany(context.Context, []Trade) error
+	GetManifest(context.Context, string) (Manifest, error)
+	SaveManifest(context.Context, Manifest) error
+	// FetchTrades returns a page of summaries by ticker.
+	FetchTrades(context.Context, TradeQuery) (TradePage, error)
+	// GetTrade summarizes a ticker for the days from one date to another,
+	// inclusive. Empty dates leave the range open.
+	GetTrade(context.Context, string, string, string) (TradeSummary, error)
+	// GetTradeDays returns the daily summaries of a ticker for the days from
+	// one date to another, inclusive, in date order.
+	GetTradeDays(context.Context, string, string, string) ([]TradeDay, error)
+	// FetchTradeDays is GetTradeDays for several tickers at once, returning
+	// the days by ticker. Tickers without trades are left out.
+	FetchTradeDays(context.Context, []string, string, string) (map[string][]TradeDay, error)
+	GetCandles(context.Context, string, string, string, string) ([]Candle, error)
+	// FetchRanking ranks tickers by a metric of their summaries.
+	FetchRanking(context.Context, RankingQuery) ([]Rank, error)
+	// GetTicks runs a TickQuery, returning its trades as rows to iterate
+	// over. Invalid queries fail here, before any trade is read.
+	GetTicks(context.Context, TickQuery) (TickRows, error)
+}
+
+// Policies is implemented by the databases able to compress and drop old
+// trades on a schedule.
+type Policies interface {
+	// SetCompression compresses trades older than an interval, such as
+	// "7 days". An empty interval stops compressing new chunks.
+	SetCompression(context.Context, string) error
+	// SetRetention drops trades older than an interval, such as "1 year",
+	// while their daily summaries are kept. An empty interval keeps trades
+	// forever.
+	SetRetention(context.Context, string) error
 }
@@ -0,0 +1,53 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrNotFound is returned, wrapped, when there is no data for a query.
+	ErrNotFound = errors.New("not found")
+	// ErrInvalidArgument is returned, wrapped, for malformed filters such as
+	// dates and candle intervals.
+	ErrInvalidArgument = errors.New("invalid argument")
+)
+
+// checkDates validates date filters before they reach a query, so malformed
+// ones are reported as ErrInvalidArgument. Empty dates are valid.
+func checkDates(dates ...string) error {
+	loc, err := loadLocation()
+	if err != nil {
+		return err
+	}
+
+	for _, date := range dates {
+		if date == "" {
+			continue
+		}
+
+		if _, err := parseDate(date, loc); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// checkInterval validates a candle interval.
+func checkInterval(interval string) error {
+	if _, ok := CandleIntervals[interval]; !ok {
+		return fmt.Errorf("%w: candle interval %q", ErrInvalidArgument, interval)
+	}
+
+	return nil
+}
+
+// checkTickers validates the tickers of a multi-ticker query.
+func checkTickers(tickers []string) error {
+	if len(tickers) == 0 {
+		return fmt.Errorf("%w: no tickers", ErrInvalidArgument)
+	}
+
+	return nil
+}
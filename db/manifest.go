@@ -0,0 +1,22 @@
+package db
+
+import "time"
+
+// Statuses of a file in the load manifest.
+const (
+	ManifestLoading = "loading"
+	ManifestLoaded  = "loaded"
+	ManifestFailed  = "failed"
+)
+
+// Manifest records a B3 file processed by the loader. A zero value Status
+// means the file was never processed.
+type Manifest struct {
+	FileName      string
+	SHA256        string
+	RowCount      int64
+	ReferenceDate time.Time
+	Status        string
+	StartedAt     time.Time
+	FinishedAt    time.Time
+}
@@ -0,0 +1,684 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// loadLocation loads B3's time zone, which days are bucketed in, once.
+var loadLocation = sync.OnceValues(func() (*time.Location, error) {
+	return time.LoadLocation("America/Sao_Paulo")
+})
+
+// candleDurations are the CandleIntervals as durations.
+var candleDurations = map[string]time.Duration{
+	"1m": time.Minute,
+	"5m": 5 * time.Minute,
+	"1h": time.Hour,
+	"1d": 24 * time.Hour,
+}
+
+// bucket mirrors time_bucket(d, t, 'America/Sao_Paulo') for durations up to
+// a day: it truncates the wall clock time of t at B3.
+func bucket(t time.Time, d time.Duration, loc *time.Location) time.Time {
+	t = t.In(loc)
+	midnight := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc)
+
+	return midnight.Add(t.Sub(midnight).Truncate(d))
+}
+
+// parseDate parses a date filter as midnight at B3, mirroring the
+// `$1::date::timestamp AT TIME ZONE 'America/Sao_Paulo'` casts in sql.go.
+func parseDate(date string, loc *time.Location) (time.Time, error) {
+	t, err := time.ParseInLocation("2006-01-02", date, loc)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%w: date %q, expected YYYY-MM-DD", ErrInvalidArgument, date)
+	}
+
+	return t, nil
+}
+
+type memoryTrade struct {
+	Trade
+	cancelled bool
+}
+
+// tradeKey is the natural key of a trade, its ticker, ID and B3 session, as
+// enforced by DELETE_REPLACED_TRADE and trade_key.
+type tradeKey struct {
+	ticker  string
+	tradeID int64
+	session string
+}
+
+// dailySummary is a row of the trade_summary view.
+type dailySummary struct {
+	date            time.Time
+	ticker          string
+	maxRangeValue   float64
+	totalQuantity   int64
+	openPrice       float64
+	closePrice      float64
+	minRangeValue   float64
+	financialVolume float64
+	tradeCount      int64
+	// first and last are the entry times of the open and close trades
+	first time.Time
+	last  time.Time
+}
+
+// tradeDay returns the day as served by GetTradeDays.
+func (d dailySummary) tradeDay() TradeDay {
+	day := TradeDay{
+		Date:            d.date.Format(time.DateOnly),
+		MaxRangeValue:   d.maxRangeValue,
+		Volume:          d.totalQuantity,
+		Open:            ptr(d.openPrice),
+		Close:           ptr(d.closePrice),
+		MinRangeValue:   ptr(d.minRangeValue),
+		FinancialVolume: ptr(d.financialVolume),
+		TradeCount:      ptr(d.tradeCount),
+		AvgTradeSize:    ptr(float64(d.totalQuantity) / float64(d.tradeCount)),
+	}
+
+	if d.totalQuantity != 0 {
+		day.VWAP = ptr(d.financialVolume / float64(d.totalQuantity))
+	}
+
+	return day
+}
+
+// Memory is a DB kept in memory, with the same semantics as PostgreSQL. It is
+// meant for tests and local experiments, since nothing is persisted.
+type Memory struct {
+	mu        sync.RWMutex
+	trades    []memoryTrade
+	keys      map[tradeKey]int
+	manifests map[string]Manifest
+}
+
+func NewMemory() *Memory {
+	return &Memory{
+		keys:      map[tradeKey]int{},
+		manifests: map[string]Manifest{},
+	}
+}
+
+func (m *Memory) CreateTable(context.Context) error { return nil }
+
+func (m *Memory) PostLoad(context.Context) error { return nil }
+
+// Refresh only validates the window, since summaries are computed on every
+// query.
+func (m *Memory) Refresh(_ context.Context, from string, to string) error {
+	_, err := between(from, to)
+	return err
+}
+
+func (m *Memory) Close() {}
+
+func (m *Memory) Ready(context.Context) error { return nil }
+
+// insert upserts a trade by its key. It must be called with the lock held.
+func (m *Memory) insert(trade Trade, loc *time.Location) {
+	// gross_amount is a NUMERIC(10, 3)
+	trade.GrossAmount = math.Round(trade.GrossAmount*1000) / 1000
+
+	if trade.TradeID == 0 {
+		m.trades = append(m.trades, memoryTrade{Trade: trade})
+		return
+	}
+
+	key := tradeKey{
+		ticker:  trade.Ticker,
+		tradeID: trade.TradeID,
+		session: trade.EntryTime.In(loc).Format(time.DateOnly),
+	}
+
+	// as in the upserts of PostgreSQL and SQLite, a trade stored again is
+	// no longer cancelled, until a cancellation loaded after it
+	if i, ok := m.keys[key]; ok {
+		m.trades[i] = memoryTrade{Trade: trade}
+		return
+	}
+
+	m.keys[key] = len(m.trades)
+	m.trades = append(m.trades, memoryTrade{Trade: trade})
+}
+
+func (m *Memory) InsertMany(_ context.Context, trades []Trade) error {
+	loc, err := loadLocation()
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, trade := range trades {
+		m.insert(trade, loc)
+	}
+
+	return nil
+}
+
+// CopyMany reads every trade from src before storing them, so nothing is
+// stored if src fails, as in the transaction used by PostgreSQL.
+func (m *Memory) CopyMany(ctx context.Context, src TradeSource) (int64, error) {
+	trades := []Trade{}
+
+	for src.Next() {
+		trades = append(trades, src.Trade())
+	}
+
+	if err := src.Err(); err != nil {
+		return 0, err
+	}
+
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	if err := m.InsertMany(ctx, trades); err != nil {
+		return 0, err
+	}
+
+	return int64(len(trades)), nil
+}
+
+func (m *Memory) CancelMany(_ context.Context, trades []Trade) error {
+	loc, err := loadLocation()
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, cancellation := range trades {
+		start := bucket(cancellation.EntryTime, 24*time.Hour, loc)
+		end := start.AddDate(0, 0, 1)
+
+		for i, trade := range m.trades {
+			if trade.Ticker != cancellation.Ticker || trade.TradeID == 0 || trade.TradeID != cancellation.TradeID {
+				continue
+			}
+
+			if trade.EntryTime.Before(start) || !trade.EntryTime.Before(end) {
+				continue
+			}
+
+			m.trades[i].cancelled = true
+		}
+	}
+
+	return nil
+}
+
+func (m *Memory) GetManifest(_ context.Context, fileName string) (Manifest, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	manifest, ok := m.manifests[fileName]
+	if !ok {
+		return Manifest{FileName: fileName}, nil
+	}
+
+	return manifest, nil
+}
+
+func (m *Memory) SaveManifest(_ context.Context, manifest Manifest) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.manifests[manifest.FileName] = manifest
+
+	return nil
+}
+
+// summaries computes the trade_summary view, sorted by ticker and date.
+func (m *Memory) summaries() ([]dailySummary, error) {
+	loc, err := loadLocation()
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	type dailyKey struct {
+		ticker string
+		date   time.Time
+	}
+
+	days := map[dailyKey]*dailySummary{}
+
+	for _, trade := range m.trades {
+		if trade.cancelled {
+			continue
+		}
+
+		key := dailyKey{ticker: trade.Ticker, date: bucket(trade.EntryTime, 24*time.Hour, loc)}
+
+		day, ok := days[key]
+		if !ok {
+			day = &dailySummary{
+				date:          key.date,
+				ticker:        key.ticker,
+				maxRangeValue: trade.GrossAmount,
+				openPrice:     trade.GrossAmount,
+				closePrice:    trade.GrossAmount,
+				minRangeValue: trade.GrossAmount,
+				first:         trade.EntryTime,
+				last:          trade.EntryTime,
+			}
+			days[key] = day
+		}
+
+		day.maxRangeValue = math.Max(day.maxRangeValue, trade.GrossAmount)
+		day.minRangeValue = math.Min(day.minRangeValue, trade.GrossAmount)
+		day.totalQuantity += trade.Quantity
+		day.financialVolume += trade.GrossAmount * float64(trade.Quantity)
+		day.tradeCount++
+
+		if trade.EntryTime.Before(day.first) {
+			day.openPrice, day.first = trade.GrossAmount, trade.EntryTime
+		}
+
+		if !trade.EntryTime.Before(day.last) {
+			day.closePrice, day.last = trade.GrossAmount, trade.EntryTime
+		}
+	}
+
+	summaries := make([]dailySummary, 0, len(days))
+	for _, day := range days {
+		summaries = append(summaries, *day)
+	}
+
+	sort.Slice(summaries, func(i, j int) bool {
+		if summaries[i].ticker != summaries[j].ticker {
+			return summaries[i].ticker < summaries[j].ticker
+		}
+
+		return summaries[i].date.Before(summaries[j].date)
+	})
+
+	return summaries, nil
+}
+
+// summarize groups daily summaries by ticker, as the queries in sql.go do,
+// keeping only the days matching keep.
+func summarize(days []dailySummary, keep func(dailySummary) bool) []TradeSummary {
+	trades := []TradeSummary{}
+
+	for _, day := range days {
+		if !keep(day) {
+			continue
+		}
+
+		if len(trades) == 0 || trades[len(trades)-1].Ticker != day.ticker {
+			trades = append(trades, TradeSummary{
+				Ticker:          day.ticker,
+				MaxRangeValue:   day.maxRangeValue,
+				Open:            ptr(day.openPrice),
+				MinRangeValue:   ptr(day.minRangeValue),
+				Volume:          ptr(int64(0)),
+				FinancialVolume: ptr(0.0),
+				TradeCount:      ptr(int64(0)),
+			})
+		}
+
+		trade := &trades[len(trades)-1]
+		trade.MaxRangeValue = math.Max(trade.MaxRangeValue, day.maxRangeValue)
+		trade.MaxDailyVolume = max(trade.MaxDailyVolume, day.totalQuantity)
+		trade.Close = ptr(day.closePrice)
+		*trade.MinRangeValue = math.Min(*trade.MinRangeValue, day.minRangeValue)
+		*trade.Volume += day.totalQuantity
+		*trade.FinancialVolume += day.financialVolume
+		*trade.TradeCount += day.tradeCount
+	}
+
+	for i := range trades {
+		trade := &trades[i]
+
+		if *trade.Volume != 0 {
+			trade.VWAP = ptr(*trade.FinancialVolume / float64(*trade.Volume))
+		}
+
+		trade.AvgTradeSize = ptr(float64(*trade.Volume) / float64(*trade.TradeCount))
+	}
+
+	return trades
+}
+
+func ptr[T any](v T) *T { return &v }
+
+// between keeps the days from one date to another, inclusive. Empty dates
+// leave the range open.
+func between(from string, to string) (func(dailySummary) bool, error) {
+	loc, err := loadLocation()
+	if err != nil {
+		return nil, err
+	}
+
+	var start, end time.Time
+
+	if from != "" {
+		if start, err = parseDate(from, loc); err != nil {
+			return nil, err
+		}
+	}
+
+	if to != "" {
+		if end, err = parseDate(to, loc); err != nil {
+			return nil, err
+		}
+	}
+
+	return func(day dailySummary) bool {
+		return (from == "" || !day.date.Before(start)) && (to == "" || !day.date.After(end))
+	}, nil
+}
+
+func (m *Memory) FetchTrades(_ context.Context, q TradeQuery) (TradePage, error) {
+	if err := q.check(); err != nil {
+		return TradePage{}, err
+	}
+
+	last, err := q.after()
+	if err != nil {
+		return TradePage{}, err
+	}
+
+	keep, err := between(q.From, q.To)
+	if err != nil {
+		return TradePage{}, err
+	}
+
+	matches := func(string) bool { return true }
+
+	if q.Ticker != "" {
+		re, err := regexp.Compile("^" + strings.ReplaceAll(regexp.QuoteMeta(strings.ToUpper(q.Ticker)), `\*`, ".*") + "$")
+		if err != nil {
+			return TradePage{}, err
+		}
+
+		matches = re.MatchString
+	}
+
+	days, err := m.summaries()
+	if err != nil {
+		return TradePage{}, err
+	}
+
+	trades := summarize(days, func(day dailySummary) bool { return matches(day.ticker) && keep(day) })
+
+	sort.Slice(trades, func(i, j int) bool { return q.less(trades[i], trades[j]) })
+
+	if last != nil {
+		i := sort.Search(len(trades), func(i int) bool { return q.less(*last, trades[i]) })
+		trades = trades[i:]
+	}
+
+	if q.Limit > 0 && len(trades) > q.Limit+1 {
+		trades = trades[:q.Limit+1]
+	}
+
+	return q.page(trades)
+}
+
+func (m *Memory) GetTrade(_ context.Context, ticker string, from string, to string) (TradeSummary, error) {
+	keep, err := between(from, to)
+	if err != nil {
+		return TradeSummary{}, err
+	}
+
+	days, err := m.summaries()
+	if err != nil {
+		return TradeSummary{}, err
+	}
+
+	trades := summarize(days, func(day dailySummary) bool { return day.ticker == ticker && keep(day) })
+
+	if len(trades) == 0 {
+		return TradeSummary{}, fmt.Errorf("%w: no trades for %s", ErrNotFound, ticker)
+	}
+
+	return trades[0], nil
+}
+
+func (m *Memory) GetTradeDays(_ context.Context, ticker string, from string, to string) ([]TradeDay, error) {
+	keep, err := between(from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	summaries, err := m.summaries()
+	if err != nil {
+		return nil, err
+	}
+
+	days := []TradeDay{}
+
+	for _, day := range summaries {
+		if day.ticker != ticker || !keep(day) {
+			continue
+		}
+
+		days = append(days, day.tradeDay())
+	}
+
+	return days, nil
+}
+
+func (m *Memory) FetchTradeDays(_ context.Context, tickers []string, from string, to string) (map[string][]TradeDay, error) {
+	if err := checkTickers(tickers); err != nil {
+		return nil, err
+	}
+
+	keep, err := between(from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	summaries, err := m.summaries()
+	if err != nil {
+		return nil, err
+	}
+
+	selected := map[string]bool{}
+	for _, ticker := range tickers {
+		selected[ticker] = true
+	}
+
+	days := map[string][]TradeDay{}
+
+	for _, day := range summaries {
+		if !selected[day.ticker] || !keep(day) {
+			continue
+		}
+
+		days[day.ticker] = append(days[day.ticker], day.tradeDay())
+	}
+
+	return days, nil
+}
+
+func (m *Memory) FetchRanking(_ context.Context, q RankingQuery) ([]Rank, error) {
+	if err := q.check(); err != nil {
+		return nil, err
+	}
+
+	keep, err := between(q.From, q.To)
+	if err != nil {
+		return nil, err
+	}
+
+	days, err := m.summaries()
+	if err != nil {
+		return nil, err
+	}
+
+	ranks := []Rank{}
+
+	for _, trade := range summarize(days, keep) {
+		if value := q.value(trade); value != nil {
+			ranks = append(ranks, Rank{Ticker: trade.Ticker, Value: *value})
+		}
+	}
+
+	sort.Slice(ranks, func(i, j int) bool {
+		if ranks[i].Value != ranks[j].Value {
+			return (ranks[i].Value > ranks[j].Value) == q.Desc
+		}
+
+		return ranks[i].Ticker < ranks[j].Ticker
+	})
+
+	if len(ranks) > q.Limit {
+		ranks = ranks[:q.Limit]
+	}
+
+	return ranks, nil
+}
+
+// memoryTickRows iterates over ticks copied out of a Memory.
+type memoryTickRows struct {
+	ticks []Tick
+	next  int
+}
+
+func (r *memoryTickRows) Next() bool {
+	if r.next >= len(r.ticks) {
+		return false
+	}
+
+	r.next++
+
+	return true
+}
+
+func (r *memoryTickRows) Tick() Tick { return r.ticks[r.next-1] }
+
+func (r *memoryTickRows) Err() error { return nil }
+
+func (r *memoryTickRows) Close() {}
+
+func (m *Memory) GetTicks(_ context.Context, q TickQuery) (TickRows, error) {
+	start, end, err := q.bounds()
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+
+	ticks := []Tick{}
+
+	for _, trade := range m.trades {
+		if trade.cancelled || trade.Ticker != q.Ticker {
+			continue
+		}
+
+		if trade.EntryTime.Before(start) || !trade.EntryTime.Before(end) {
+			continue
+		}
+
+		ticks = append(ticks, Tick{
+			Time:     trade.EntryTime.In(start.Location()),
+			Price:    trade.GrossAmount,
+			Quantity: trade.Quantity,
+			TradeID:  trade.TradeID,
+		})
+	}
+
+	m.mu.RUnlock()
+
+	sort.SliceStable(ticks, func(i, j int) bool {
+		if !ticks[i].Time.Equal(ticks[j].Time) {
+			return ticks[i].Time.Before(ticks[j].Time)
+		}
+
+		return ticks[i].TradeID < ticks[j].TradeID
+	})
+
+	return &memoryTickRows{ticks: ticks}, nil
+}
+
+func (m *Memory) GetCandles(_ context.Context, ticker string, interval string, from string, to string) ([]Candle, error) {
+	if err := checkInterval(interval); err != nil {
+		return nil, err
+	}
+
+	d := candleDurations[interval]
+
+	loc, err := loadLocation()
+	if err != nil {
+		return nil, err
+	}
+
+	var start, end time.Time
+
+	if from != "" {
+		if start, err = parseDate(from, loc); err != nil {
+			return nil, err
+		}
+	}
+
+	if to != "" {
+		if end, err = parseDate(to, loc); err != nil {
+			return nil, err
+		}
+
+		end = end.AddDate(0, 0, 1)
+	}
+
+	m.mu.RLock()
+
+	trades := []Trade{}
+
+	for _, trade := range m.trades {
+		if trade.cancelled || trade.Ticker != ticker {
+			continue
+		}
+
+		if (from != "" && trade.EntryTime.Before(start)) || (to != "" && !trade.EntryTime.Before(end)) {
+			continue
+		}
+
+		trades = append(trades, trade.Trade)
+	}
+
+	m.mu.RUnlock()
+
+	sort.SliceStable(trades, func(i, j int) bool { return trades[i].EntryTime.Before(trades[j].EntryTime) })
+
+	candles := []Candle{}
+
+	for _, trade := range trades {
+		t := bucket(trade.EntryTime, d, loc)
+
+		if len(candles) == 0 || !candles[len(candles)-1].Time.Equal(t) {
+			candles = append(candles, Candle{
+				Time: t,
+				Open: trade.GrossAmount,
+				High: trade.GrossAmount,
+				Low:  trade.GrossAmount,
+			})
+		}
+
+		candle := &candles[len(candles)-1]
+		candle.High = math.Max(candle.High, trade.GrossAmount)
+		candle.Low = math.Min(candle.Low, trade.GrossAmount)
+		candle.Close = trade.GrossAmount
+		candle.Volume += trade.Quantity
+		candle.TradeCount++
+	}
+
+	return candles, nil
+}
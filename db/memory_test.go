@@ -0,0 +1,7 @@
+package db
+
+import "testing"
+
+func TestMemory(t *testing.T) {
+	testDB(t, func(t *testing.T) DB { return NewMemory() })
+}
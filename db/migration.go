@@ -0,0 +1,117 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Migration is a numbered change to the schema. Up applies it and Down
+// reverts it, each statement running in order within a single transaction
+// that also records the version in schema_migrations.
+type Migration struct {
+	Version int
+	Name    string
+	Up      []string
+	Down    []string
+}
+
+// MigrationStatus tells whether a migration is applied, and when.
+type MigrationStatus struct {
+	Version   int
+	Name      string
+	AppliedAt time.Time
+}
+
+func (s MigrationStatus) Applied() bool { return !s.AppliedAt.IsZero() }
+
+// Migrator is implemented by the databases with a versioned schema.
+type Migrator interface {
+	// MigrateUp applies every pending migration, returning how many ran.
+	MigrateUp(context.Context) (int, error)
+	// MigrateDown reverts the last steps applied migrations, returning how
+	// many were reverted.
+	MigrateDown(context.Context, int) (int, error)
+	MigrationStatus(context.Context) ([]MigrationStatus, error)
+}
+
+// migrationStore runs migrations against a database and keeps track of them.
+type migrationStore interface {
+	// applied creates schema_migrations if needed and returns when each
+	// applied version ran.
+	applied(context.Context) (map[int]time.Time, error)
+	// apply runs the statements of a migration and records it as applied,
+	// or as reverted when up is false.
+	apply(ctx context.Context, m Migration, up bool) error
+}
+
+func migrateUp(ctx context.Context, s migrationStore, migrations []Migration) (int, error) {
+	applied, err := s.applied(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	n := 0
+
+	for _, m := range migrations {
+		if _, ok := applied[m.Version]; ok {
+			continue
+		}
+
+		if err := s.apply(ctx, m, true); err != nil {
+			return n, fmt.Errorf("could not apply migration %d %s: %w", m.Version, m.Name, err)
+		}
+
+		n++
+	}
+
+	return n, nil
+}
+
+func migrateDown(ctx context.Context, s migrationStore, migrations []Migration, steps int) (int, error) {
+	if steps < 1 {
+		return 0, fmt.Errorf("invalid number of steps %d, expected at least 1", steps)
+	}
+
+	applied, err := s.applied(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	n := 0
+
+	for i := len(migrations) - 1; i >= 0 && n < steps; i-- {
+		m := migrations[i]
+
+		if _, ok := applied[m.Version]; !ok {
+			continue
+		}
+
+		if err := s.apply(ctx, m, false); err != nil {
+			return n, fmt.Errorf("could not revert migration %d %s: %w", m.Version, m.Name, err)
+		}
+
+		n++
+	}
+
+	return n, nil
+}
+
+func migrationStatus(ctx context.Context, s migrationStore, migrations []Migration) ([]MigrationStatus, error) {
+	applied, err := s.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	status := make([]MigrationStatus, 0, len(migrations))
+
+	for _, m := range migrations {
+		status = append(status, MigrationStatus{
+			Version:   m.Version,
+			Name:      m.Name,
+			AppliedAt: applied[m.Version],
+		})
+	}
+
+	return status, nil
+}
@@ -0,0 +1,41 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// Open connects to the database at uri, choosing the backend by its scheme:
+// postgres:// and postgresql:// for PostgreSQL with TimescaleDB and
+// sqlite:///path/to/file.db for an embedded SQLite file. URIs without a
+// scheme, such as libpq key=value connection strings, are PostgreSQL.
+func Open(ctx context.Context, uri string) (DB, error) {
+	scheme, rest, ok := strings.Cut(uri, "://")
+	if !ok {
+		scheme = "postgres"
+	}
+
+	switch scheme {
+	case "postgres", "postgresql":
+		pg, err := NewPostgreSQL(ctx, uri)
+		if err != nil {
+			return nil, err
+		}
+
+		return &pg, nil
+	case "sqlite":
+		if rest == "" {
+			return nil, fmt.Errorf("invalid database URI %q, expected sqlite:///path/to/file.db", uri)
+		}
+
+		s, err := NewSQLite(ctx, rest)
+		if err != nil {
+			return nil, err
+		}
+
+		return &s, nil
+	default:
+		return nil, fmt.Errorf("unsupported database URI scheme %q", scheme)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
@@ -16,82 +17,253 @@ type PostgreSQL struct {
 
 func (p *PostgreSQL) Close() { p.pool.Close() }
 
-func (p *PostgreSQL) InsertMany(trades []Trade) error {
+// Stat returns the statistics of the connection pool.
+func (p *PostgreSQL) Stat() *pgxpool.Stat { return p.pool.Stat() }
+
+func (p *PostgreSQL) Ready(ctx context.Context) error {
+	if err := p.pool.Ping(ctx); err != nil {
+		return err
+	}
+
+	var exists bool
+
+	if err := p.pool.QueryRow(ctx, TRADE_SUMMARY_EXISTS).Scan(&exists); err != nil {
+		return err
+	}
+
+	if !exists {
+		return errors.New("trade_summary does not exist, run the migrations")
+	}
+
+	return nil
+}
+
+func (p *PostgreSQL) InsertMany(ctx context.Context, trades []Trade) error {
 	batch := &pgx.Batch{}
 
 	for _, trade := range trades {
-		batch.Queue(CREATE_TRADE, trade.Ticker, trade.GrossAmount, trade.Quantity, trade.EntryTime, trade.Date)
+		batch.Queue(DELETE_REPLACED_TRADE, trade.Ticker, trade.TradeID, trade.EntryTime)
+		batch.Queue(
+			CREATE_TRADE,
+			trade.Ticker,
+			trade.GrossAmount,
+			trade.Quantity,
+			trade.EntryTime,
+			trade.ReferenceDate,
+			trade.UpdateAction,
+			trade.TradeID,
+			trade.SessionType,
+			trade.BuyerCode,
+			trade.SellerCode,
+		)
 	}
 
-	result := p.pool.SendBatch(context.Background(), batch)
+	result := p.pool.SendBatch(ctx, batch)
 	defer result.Close()
 
-	if result == nil {
-		return errors.New("got empty result while creating trades")
+	// each trade queued a delete and an insert
+	for range 2 * len(trades) {
+		if _, err := result.Exec(); err != nil {
+			return fmt.Errorf("could not create trades: %w", err)
+		}
 	}
 
-	return nil
+	return result.Close()
+}
+
+// copyColumns are the trade columns written by CopyMany, in the order of
+// copySource.Values.
+var copyColumns = []string{
+	"ticker",
+	"gross_amount",
+	"quantity",
+	"entry_time",
+	"reference_date",
+	"update_action",
+	"trade_id",
+	"session_type",
+	"buyer_code",
+	"seller_code",
+}
+
+// copySource adapts a TradeSource to pgx.CopyFromSource.
+type copySource struct {
+	TradeSource
+}
+
+func (s copySource) Values() ([]any, error) {
+	trade := s.Trade()
+
+	return []any{
+		trade.Ticker,
+		trade.GrossAmount,
+		trade.Quantity,
+		trade.EntryTime,
+		nullableTime(trade.ReferenceDate),
+		trade.UpdateAction,
+		nullableInt(trade.TradeID),
+		trade.SessionType,
+		nullableInt(int64(trade.BuyerCode)),
+		nullableInt(int64(trade.SellerCode)),
+	}, nil
 }
 
-func (p *PostgreSQL) FetchTrades(date string) ([]TradeSummary, error) {
+// CopyMany streams trades into a staging table using COPY and merges them
+// into trade in a single transaction, returning how many trades were copied.
+func (p *PostgreSQL) CopyMany(ctx context.Context, src TradeSource) (int64, error) {
+	tx, err := p.pool.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, CREATE_TRADE_STAGING); err != nil {
+		return 0, err
+	}
+
+	n, err := tx.CopyFrom(ctx, pgx.Identifier{"trade_staging"}, copyColumns, copySource{src})
+	if err != nil {
+		return 0, fmt.Errorf("could not copy trades: %w", err)
+	}
+
+	for _, sql := range []string{ANALYZE_TRADE_STAGING, DELETE_STAGING_DUPLICATES, DELETE_REPLACED_STAGING, MERGE_TRADE_STAGING} {
+		if _, err := tx.Exec(ctx, sql); err != nil {
+			return 0, fmt.Errorf("could not merge copied trades: %w", err)
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return 0, err
+	}
+
+	return n, nil
+}
+
+func (p *PostgreSQL) CancelMany(ctx context.Context, trades []Trade) error {
+	batch := &pgx.Batch{}
+
+	for _, trade := range trades {
+		batch.Queue(CANCEL_TRADE, trade.Ticker, trade.TradeID, trade.EntryTime)
+	}
+
+	result := p.pool.SendBatch(ctx, batch)
+	defer result.Close()
+
+	for range trades {
+		if _, err := result.Exec(); err != nil {
+			return fmt.Errorf("could not cancel trades: %w", err)
+		}
+	}
+
+	return result.Close()
+}
+
+func (p *PostgreSQL) GetManifest(ctx context.Context, fileName string) (Manifest, error) {
 	var (
-		rows pgx.Rows
-		err  error
+		manifest      Manifest
+		referenceDate *time.Time
+		finishedAt    *time.Time
 	)
 
-	if date != "" {
-		rows, err = p.pool.Query(context.Background(), FETCH_BY_DATE, date)
-	} else {
-		rows, err = p.pool.Query(context.Background(), FETCH_ALL)
+	err := p.pool.QueryRow(ctx, GET_MANIFEST, fileName).Scan(
+		&manifest.FileName,
+		&manifest.SHA256,
+		&manifest.RowCount,
+		&referenceDate,
+		&manifest.Status,
+		&manifest.StartedAt,
+		&finishedAt,
+	)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return Manifest{FileName: fileName}, nil
+		}
+
+		return Manifest{}, err
+	}
+
+	if referenceDate != nil {
+		manifest.ReferenceDate = *referenceDate
+	}
+
+	if finishedAt != nil {
+		manifest.FinishedAt = *finishedAt
+	}
+
+	return manifest, nil
+}
+
+func (p *PostgreSQL) SaveManifest(ctx context.Context, manifest Manifest) error {
+	_, err := p.pool.Exec(
+		ctx,
+		SAVE_MANIFEST,
+		manifest.FileName,
+		manifest.SHA256,
+		manifest.RowCount,
+		nullableTime(manifest.ReferenceDate),
+		manifest.Status,
+		manifest.StartedAt,
+		nullableTime(manifest.FinishedAt),
+	)
+
+	return err
+}
+
+func (p *PostgreSQL) FetchTrades(ctx context.Context, q TradeQuery) (TradePage, error) {
+	if err := q.check(); err != nil {
+		return TradePage{}, err
 	}
 
+	after, err := q.cursorArgs()
 	if err != nil {
-		return nil, err
+		return TradePage{}, err
+	}
+
+	var limit any
+	if q.Limit > 0 {
+		limit = q.Limit + 1
+	}
+
+	rows, err := p.pool.Query(ctx, q.orderBy(FETCH_TRADES_PAGE), nullableDate(q.From), nullableDate(q.To), q.pattern(), after[0], after[1], limit)
+	if err != nil {
+		return TradePage{}, err
 	}
 
 	defer rows.Close()
 
-	var trades []TradeSummary
+	trades := []TradeSummary{}
 
 	for rows.Next() {
 		var trade TradeSummary
 
-		err := rows.Scan(&trade.Ticker, &trade.MaxRangeValue, &trade.MaxDailyVolume)
+		err := rows.Scan(trade.columns()...)
 		if err != nil {
-			if err == pgx.ErrNoRows {
-				return []TradeSummary{}, nil
-			}
-
-			return nil, err
+			return TradePage{}, err
 		}
 
 		trades = append(trades, trade)
 	}
 
-	return trades, nil
-}
+	if err := rows.Err(); err != nil {
+		return TradePage{}, err
+	}
 
-func (p *PostgreSQL) GetTrade(ticker string, date string) (TradeSummary, error) {
-	var row pgx.Row
+	return q.page(trades)
+}
 
-	if date != "" {
-		row = p.pool.QueryRow(context.Background(), GET_BY_TICKER_AND_DATE, ticker, date)
-	} else {
-		row = p.pool.QueryRow(context.Background(), GET_BY_TICKER, ticker)
+func (p *PostgreSQL) GetTrade(ctx context.Context, ticker string, from string, to string) (TradeSummary, error) {
+	if err := checkDates(from, to); err != nil {
+		return TradeSummary{}, err
 	}
 
+	row := p.pool.QueryRow(ctx, GET_TRADE, ticker, nullableDate(from), nullableDate(to))
+
 	var trade TradeSummary
 
-	err := row.Scan(&trade.Ticker, &trade.MaxRangeValue, &trade.MaxDailyVolume)
+	err := row.Scan(trade.columns()...)
 	if err != nil {
 		if err == pgx.ErrNoRows {
-			emptyTrade := TradeSummary{
-				Ticker:         ticker,
-				MaxRangeValue:  0,
-				MaxDailyVolume: 0,
-			}
-
-			return emptyTrade, nil
+			return TradeSummary{}, fmt.Errorf("%w: no trades for %s", ErrNotFound, ticker)
 		}
 
 		return TradeSummary{}, err
@@ -100,49 +272,535 @@ func (p *PostgreSQL) GetTrade(ticker string, date string) (TradeSummary, error)
 	return trade, nil
 }
 
-func (p *PostgreSQL) CreateTable() error {
-	if _, err := p.pool.Exec(context.Background(), CREATE_TABLE); err != nil {
+func (p *PostgreSQL) GetTradeDays(ctx context.Context, ticker string, from string, to string) ([]TradeDay, error) {
+	if err := checkDates(from, to); err != nil {
+		return nil, err
+	}
+
+	rows, err := p.pool.Query(ctx, GET_TRADE_DAYS, ticker, nullableDate(from), nullableDate(to))
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	days := []TradeDay{}
+
+	for rows.Next() {
+		var day TradeDay
+
+		if err := rows.Scan(day.columns()...); err != nil {
+			return nil, err
+		}
+
+		days = append(days, day)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return days, nil
+}
+
+func (p *PostgreSQL) FetchTradeDays(ctx context.Context, tickers []string, from string, to string) (map[string][]TradeDay, error) {
+	if err := checkTickers(tickers); err != nil {
+		return nil, err
+	}
+
+	if err := checkDates(from, to); err != nil {
+		return nil, err
+	}
+
+	rows, err := p.pool.Query(ctx, FETCH_TRADE_DAYS, tickers, nullableDate(from), nullableDate(to))
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	days := map[string][]TradeDay{}
+
+	for rows.Next() {
+		var (
+			ticker string
+			day    TradeDay
+		)
+
+		if err := rows.Scan(append([]any{&ticker}, day.columns()...)...); err != nil {
+			return nil, err
+		}
+
+		days[ticker] = append(days[ticker], day)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return days, nil
+}
+
+func (p *PostgreSQL) FetchRanking(ctx context.Context, q RankingQuery) ([]Rank, error) {
+	if err := q.check(); err != nil {
+		return nil, err
+	}
+
+	query := fmt.Sprintf(FETCH_RANKING, rankingMetrics[q.Metric].postgres, q.direction())
+
+	rows, err := p.pool.Query(ctx, query, nullableDate(q.From), nullableDate(q.To), q.Limit)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	ranks := []Rank{}
+
+	for rows.Next() {
+		var rank Rank
+
+		if err := rows.Scan(&rank.Ticker, &rank.Value); err != nil {
+			return nil, err
+		}
+
+		ranks = append(ranks, rank)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return ranks, nil
+}
+
+func (p *PostgreSQL) GetCandles(ctx context.Context, ticker string, interval string, from string, to string) ([]Candle, error) {
+	if err := checkInterval(interval); err != nil {
+		return nil, err
+	}
+
+	if err := checkDates(from, to); err != nil {
+		return nil, err
+	}
+
+	rows, err := p.pool.Query(ctx, GET_CANDLES, ticker, CandleIntervals[interval], nullableDate(from), nullableDate(to))
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	candles := []Candle{}
+
+	for rows.Next() {
+		var candle Candle
+
+		err := rows.Scan(
+			&candle.Time,
+			&candle.Open,
+			&candle.High,
+			&candle.Low,
+			&candle.Close,
+			&candle.Volume,
+			&candle.TradeCount,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		candles = append(candles, candle)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return candles, nil
+}
+
+// pgTickRows reads ticks from pgx rows, which are received from the server
+// as they are iterated over.
+type pgTickRows struct {
+	rows pgx.Rows
+	loc  *time.Location
+	tick Tick
+	err  error
+}
+
+func (r *pgTickRows) Next() bool {
+	if r.err != nil || !r.rows.Next() {
+		return false
+	}
+
+	r.err = r.rows.Scan(&r.tick.Time, &r.tick.Price, &r.tick.Quantity, &r.tick.TradeID)
+	r.tick.Time = r.tick.Time.In(r.loc)
+
+	return r.err == nil
+}
+
+func (r *pgTickRows) Tick() Tick { return r.tick }
+
+func (r *pgTickRows) Err() error {
+	if r.err != nil {
+		return r.err
+	}
+
+	return r.rows.Err()
+}
+
+func (r *pgTickRows) Close() { r.rows.Close() }
+
+func (p *PostgreSQL) GetTicks(ctx context.Context, q TickQuery) (TickRows, error) {
+	start, end, err := q.bounds()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := p.pool.Query(ctx, GET_TICKS, q.Ticker, start, end)
+	if err != nil {
+		return nil, err
+	}
+
+	return &pgTickRows{rows: rows, loc: start.Location()}, nil
+}
+
+// postgresMigrations is the schema, in order. The first ones use IF NOT EXISTS
+// so databases created before migrations were tracked adopt them as applied.
+var postgresMigrations = []Migration{
+	{
+		Version: 1,
+		Name:    "create_trade",
+		// trades stored by previous versions, with separate date and time
+		// columns, are moved into the hypertable
+		Up:   []string{MIGRATE_LEGACY_TRADE, CREATE_TABLE, CREATE_HYPERTABLE, COPY_LEGACY_TRADE},
+		Down: []string{DROP_MATERIALIZED_VIEW, DROP_TABLE},
+	},
+	{
+		Version: 2,
+		Name:    "add_trade_columns",
+		Up:      []string{ADD_TRADE_COLUMNS},
+		Down:    []string{DROP_MATERIALIZED_VIEW, DROP_TRADE_COLUMNS},
+	},
+	{
+		Version: 3,
+		Name:    "create_trade_key",
+		Up:      []string{CREATE_TRADE_KEY},
+		Down:    []string{DROP_TRADE_KEY},
+	},
+	{
+		Version: 4,
+		Name:    "create_load_manifest",
+		Up:      []string{CREATE_LOAD_MANIFEST},
+		Down:    []string{DROP_LOAD_MANIFEST},
+	},
+	{
+		Version: 5,
+		Name:    "create_trade_summary",
+		// the materialized view created by previous versions is replaced
+		Up:   []string{DROP_MATERIALIZED_VIEW, CREATE_CONTINUOUS_AGGREGATE, CREATE_INDEXES, ADD_REFRESH_POLICY},
+		Down: []string{REMOVE_REFRESH_POLICY, DROP_MATERIALIZED_VIEW},
+	},
+	{
+		Version: 6,
+		Name:    "add_trade_summary_stats",
+		// trade_summary is left as is, since it keeps the days whose trades
+		// were dropped by retention, and the statistics are summarized on
+		// their own, following its refresh policy
+		Up:   []string{CREATE_TRADE_SUMMARY_STATS, CREATE_TRADE_SUMMARY_STATS_INDEXES, ADD_STATS_REFRESH_POLICY},
+		Down: []string{REMOVE_STATS_REFRESH_POLICY, DROP_TRADE_SUMMARY_STATS},
+	},
+	{
+		Version: 7,
+		Name:    "deduplicate_trade_sessions",
+		// removed duplicates cannot be restored
+		Up:   []string{DEDUPLICATE_TRADE_SESSIONS},
+		Down: []string{},
+	},
+}
+
+func (p *PostgreSQL) applied(ctx context.Context) (map[int]time.Time, error) {
+	if _, err := p.pool.Exec(ctx, CREATE_SCHEMA_MIGRATIONS); err != nil {
+		return nil, err
+	}
+
+	rows, err := p.pool.Query(ctx, GET_SCHEMA_MIGRATIONS)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := map[int]time.Time{}
+
+	for rows.Next() {
+		var (
+			version   int
+			appliedAt time.Time
+		)
+
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, err
+		}
+
+		applied[version] = appliedAt
+	}
+
+	return applied, rows.Err()
+}
+
+func (p *PostgreSQL) apply(ctx context.Context, m Migration, up bool) error {
+	tx, err := p.pool.Begin(ctx)
+	if err != nil {
 		return err
 	}
+	defer tx.Rollback(ctx)
+
+	statements := m.Down
+	if up {
+		statements = m.Up
+	}
 
-	if _, err := p.pool.Exec(context.Background(), CREATE_HYPERTABLE); err != nil {
+	for _, sql := range statements {
+		if _, err := tx.Exec(ctx, sql); err != nil {
+			return err
+		}
+	}
+
+	if up {
+		_, err = tx.Exec(ctx, INSERT_SCHEMA_MIGRATION, m.Version, m.Name)
+	} else {
+		_, err = tx.Exec(ctx, DELETE_SCHEMA_MIGRATION, m.Version)
+	}
+
+	if err != nil {
 		return err
 	}
 
-	return nil
+	return tx.Commit(ctx)
+}
+
+func (p *PostgreSQL) MigrateUp(ctx context.Context) (int, error) {
+	return migrateUp(ctx, p, postgresMigrations)
 }
 
-func (p *PostgreSQL) DropTable() error {
-	if _, err := p.pool.Exec(context.Background(), DROP_MATERIALIZED_VIEW); err != nil {
+func (p *PostgreSQL) MigrateDown(ctx context.Context, steps int) (int, error) {
+	return migrateDown(ctx, p, postgresMigrations, steps)
+}
+
+func (p *PostgreSQL) MigrationStatus(ctx context.Context) ([]MigrationStatus, error) {
+	return migrationStatus(ctx, p, postgresMigrations)
+}
+
+// CreateTable applies the pending migrations.
+func (p *PostgreSQL) CreateTable(ctx context.Context) error {
+	_, err := p.MigrateUp(ctx)
+	return err
+}
+
+func (p *PostgreSQL) DropTable(ctx context.Context) error {
+	if _, err := p.pool.Exec(ctx, DROP_TRADE_SUMMARY_STATS); err != nil {
 		return err
 	}
 
-	if _, err := p.pool.Exec(context.Background(), DROP_TABLE); err != nil {
+	if _, err := p.pool.Exec(ctx, DROP_MATERIALIZED_VIEW); err != nil {
+		return err
+	}
+
+	if _, err := p.pool.Exec(ctx, DROP_TABLE); err != nil {
+		return err
+	}
+
+	if _, err := p.pool.Exec(ctx, DROP_LOAD_MANIFEST); err != nil {
+		return err
+	}
+
+	if _, err := p.pool.Exec(ctx, DROP_SCHEMA_MIGRATIONS); err != nil {
 		return err
 	}
 
 	return nil
 }
 
-func (p *PostgreSQL) PostLoad() error {
-	if _, err := p.pool.Exec(context.Background(), CREATE_MATERIALIZED_VIEW); err != nil {
+// PostLoad refreshes trade_summary and trade_summary_stats. The whole
+// window is refreshed, but only the days changed by the load are
+// materialized again.
+func (p *PostgreSQL) PostLoad(ctx context.Context) error {
+	return p.Refresh(ctx, "", "")
+}
+
+// Refresh materializes the days from from to to, inclusive. With retention,
+// the days before the retention horizon are left out, as in the refresh
+// policies, since refreshing days whose trades were dropped would empty
+// their summaries.
+func (p *PostgreSQL) Refresh(ctx context.Context, from string, to string) error {
+	start, end, err := p.refreshWindow(ctx, from, to)
+	if err != nil {
 		return err
 	}
 
-	if _, err := p.pool.Exec(context.Background(), CREATE_INDEXES); err != nil {
-		return err
+	if !start.IsZero() && !end.IsZero() && !start.Before(end) {
+		return nil
+	}
+
+	// refresh_continuous_aggregate cannot run within a transaction, so it
+	// runs on its own
+	for _, view := range []string{"trade_summary", "trade_summary_stats"} {
+		if _, err := p.pool.Exec(ctx, fmt.Sprintf(REFRESH_TRADE_SUMMARY, view), nullableTime(start), nullableTime(end)); err != nil {
+			return fmt.Errorf("could not refresh %s: %w", view, err)
+		}
 	}
 
 	return nil
 }
 
-func NewPostgreSQL(uri string) (PostgreSQL, error) {
+// refreshWindow returns the window of entry times Refresh materializes, from
+// inclusive and to exclusive. Zero times leave the window open.
+func (p *PostgreSQL) refreshWindow(ctx context.Context, from string, to string) (time.Time, time.Time, error) {
+	loc, err := loadLocation()
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+
+	var start, end time.Time
+
+	if from != "" {
+		if start, err = parseDate(from, loc); err != nil {
+			return time.Time{}, time.Time{}, err
+		}
+	}
+
+	if to != "" {
+		if end, err = parseDate(to, loc); err != nil {
+			return time.Time{}, time.Time{}, err
+		}
+
+		end = end.AddDate(0, 0, 1)
+	}
+
+	var horizon time.Time
+
+	err = p.pool.QueryRow(ctx, RETENTION_HORIZON).Scan(&horizon)
+	if err != nil && err != pgx.ErrNoRows {
+		return time.Time{}, time.Time{}, fmt.Errorf("could not read retention policy: %w", err)
+	}
+
+	if err == nil {
+		if first := retentionStart(horizon, loc); start.Before(first) {
+			start = first
+		}
+	}
+
+	return start, end, nil
+}
+
+// retentionStart returns the first B3 midnight at or after horizon, the start
+// of the first day whose trades are all retained. refresh_continuous_aggregate
+// only takes windows aligned to the days of the aggregates.
+func retentionStart(horizon time.Time, loc *time.Location) time.Time {
+	h := horizon.In(loc)
+	start := time.Date(h.Year(), h.Month(), h.Day(), 0, 0, 0, 0, loc)
+
+	if start.Before(h) {
+		start = start.AddDate(0, 0, 1)
+	}
+
+	return start
+}
+
+func (p *PostgreSQL) SetCompression(ctx context.Context, after string) error {
+	tx, err := p.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, REMOVE_COMPRESSION_POLICY); err != nil {
+		return err
+	}
+
+	if after != "" {
+		if _, err := tx.Exec(ctx, ENABLE_COMPRESSION); err != nil {
+			return fmt.Errorf("could not enable compression: %w", err)
+		}
+
+		if _, err := tx.Exec(ctx, ADD_COMPRESSION_POLICY, after); err != nil {
+			return fmt.Errorf("could not add compression policy: %w", err)
+		}
+	}
+
+	return tx.Commit(ctx)
+}
+
+// SetRetention also limits the refresh policies of trade_summary and
+// trade_summary_stats to the retained trades.
+func (p *PostgreSQL) SetRetention(ctx context.Context, keep string) error {
+	tx, err := p.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	for _, sql := range []string{REMOVE_RETENTION_POLICY, REMOVE_REFRESH_POLICY, REMOVE_STATS_REFRESH_POLICY} {
+		if _, err := tx.Exec(ctx, sql); err != nil {
+			return err
+		}
+	}
+
+	if keep == "" {
+		if _, err := tx.Exec(ctx, ADD_REFRESH_POLICY); err != nil {
+			return err
+		}
+	} else {
+		if _, err := tx.Exec(ctx, ADD_REFRESH_POLICY_SINCE, keep); err != nil {
+			return fmt.Errorf("could not add refresh policy: %w", err)
+		}
+
+		if _, err := tx.Exec(ctx, ADD_RETENTION_POLICY, keep); err != nil {
+			return fmt.Errorf("could not add retention policy: %w", err)
+		}
+	}
+
+	// copies the window of the refresh policy of trade_summary just added
+	if _, err := tx.Exec(ctx, ADD_STATS_REFRESH_POLICY); err != nil {
+		return fmt.Errorf("could not add stats refresh policy: %w", err)
+	}
+
+	return tx.Commit(ctx)
+}
+
+// nullableDate turns an empty date filter into a SQL NULL.
+func nullableDate(date string) any {
+	if date == "" {
+		return nil
+	}
+
+	return date
+}
+
+// nullableTime turns a zero time into a SQL NULL.
+func nullableTime(t time.Time) any {
+	if t.IsZero() {
+		return nil
+	}
+
+	return t
+}
+
+// nullableInt turns a zero integer into a SQL NULL.
+func nullableInt(i int64) any {
+	if i == 0 {
+		return nil
+	}
+
+	return i
+}
+
+func NewPostgreSQL(ctx context.Context, uri string) (PostgreSQL, error) {
 	cfg, err := pgxpool.ParseConfig(uri)
 	if err != nil {
 		return PostgreSQL{}, fmt.Errorf("could not create database config: %w", err)
 	}
 
-	conn, err := pgxpool.NewWithConfig(context.Background(), cfg)
+	conn, err := pgxpool.NewWithConfig(ctx, cfg)
 	if err != nil {
 		return PostgreSQL{}, fmt.Errorf("could not connect to the database: %w", err)
 	}
@@ -152,7 +810,7 @@ func NewPostgreSQL(uri string) (PostgreSQL, error) {
 		uri:  uri,
 	}
 
-	if err := p.pool.Ping(context.Background()); err != nil {
+	if err := p.pool.Ping(ctx); err != nil {
 		return PostgreSQL{}, fmt.Errorf("could not connect to postgres: %w", err)
 	}
 
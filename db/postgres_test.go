@@ -1,6 +1,7 @@
 package db
 
 import (
+	"context"
 	"os"
 	"testing"
 	"time"
@@ -13,7 +14,19 @@ const ANOTHER_TICKER = "4N0TH3RTICK3R"
 
 var TEST_DATABASE_URL string = os.Getenv("TEST_DATABASE_URL")
 
+// skipWithoutDatabase skips integration tests when there is no database to
+// run them against.
+func skipWithoutDatabase(t *testing.T) {
+	t.Helper()
+
+	if TEST_DATABASE_URL == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+}
+
 func TestMaxRangeValue(t *testing.T) {
+	skipWithoutDatabase(t)
+
 	var (
 		lower_amount   float64 = 1
 		average_amount float64 = 1.5
@@ -26,43 +39,41 @@ func TestMaxRangeValue(t *testing.T) {
 			GrossAmount: lower_amount,
 			Quantity:    1,
 			EntryTime:   time.Now().AddDate(0, 0, -2),
-			Date:        time.Now().AddDate(0, 0, -2),
 		},
 		{
 			Ticker:      TICKER,
 			GrossAmount: average_amount,
 			Quantity:    1,
 			EntryTime:   time.Now().AddDate(0, 0, -1),
-			Date:        time.Now().AddDate(0, 0, -1),
 		},
 		{
 			Ticker:      TICKER,
 			GrossAmount: higher_amount,
 			Quantity:    1,
 			EntryTime:   time.Now(),
-			Date:        time.Now(),
 		},
 	}
 
-	pg, err := NewPostgreSQL(TEST_DATABASE_URL)
+	pg, err := NewPostgreSQL(context.Background(), TEST_DATABASE_URL)
 	assert.NoError(t, err, "expected no error connecting to postgres, got %s", err)
 	defer func() {
-		if err := pg.DropTable(); err != nil {
+		if err := pg.DropTable(context.Background()); err != nil {
 			t.Errorf("expected no error dropping the table, got %s", err)
 		}
 		pg.Close()
 	}()
 
-	err = pg.CreateTable()
+	err = pg.CreateTable(context.Background())
 	assert.NoError(t, err, "expected no error creating table in postgres, got %s", err)
 
-	err = pg.InsertMany(trades)
+	err = pg.InsertMany(context.Background(), trades)
 	assert.NoError(t, err, "expected no error inserting trades, got %s", err)
 
-	err = pg.PostLoad()
+	err = pg.PostLoad(context.Background())
 	assert.NoError(t, err, "expected no error creating views in postgres, got %s", err)
 
-	summaries, err := pg.FetchTrades("")
+	page, err := pg.FetchTrades(context.Background(), TradeQuery{})
+	summaries := page.Trades
 	assert.NoError(t, err, "expected no error fetching trades, got %s", err)
 	assert.Equal(t, len(summaries), 1, "expected a single summary by ticker, got %v", summaries)
 
@@ -71,6 +82,8 @@ func TestMaxRangeValue(t *testing.T) {
 }
 
 func TestMaxDailyVolume(t *testing.T) {
+	skipWithoutDatabase(t)
+
 	var (
 		amount                 float64 = 1
 		expectedMaxDailyVolume int64   = 40
@@ -82,43 +95,41 @@ func TestMaxDailyVolume(t *testing.T) {
 			GrossAmount: float64(amount),
 			Quantity:    20,
 			EntryTime:   time.Now().AddDate(0, 0, -1),
-			Date:        time.Now().AddDate(0, 0, -1),
 		},
 		{
 			Ticker:      TICKER,
 			GrossAmount: amount,
 			Quantity:    10,
 			EntryTime:   time.Now(),
-			Date:        time.Now(),
 		},
 		{
 			Ticker:      TICKER,
 			GrossAmount: amount,
 			Quantity:    30,
 			EntryTime:   time.Now(),
-			Date:        time.Now(),
 		},
 	}
 
-	pg, err := NewPostgreSQL(TEST_DATABASE_URL)
+	pg, err := NewPostgreSQL(context.Background(), TEST_DATABASE_URL)
 	assert.NoError(t, err, "expected no error connecting to postgres, got %s", err)
 	defer func() {
-		if err := pg.DropTable(); err != nil {
+		if err := pg.DropTable(context.Background()); err != nil {
 			t.Errorf("expected no error dropping the table, got %s", err)
 		}
 		pg.Close()
 	}()
 
-	err = pg.CreateTable()
+	err = pg.CreateTable(context.Background())
 	assert.NoError(t, err, "expected no error creating table in postgres, got %s", err)
 
-	err = pg.InsertMany(trades)
+	err = pg.InsertMany(context.Background(), trades)
 	assert.NoError(t, err, "expected no error inserting trades, got %s", err)
 
-	err = pg.PostLoad()
+	err = pg.PostLoad(context.Background())
 	assert.NoError(t, err, "expected no error creating views in postgres, got %s", err)
 
-	summaries, err := pg.FetchTrades("")
+	page, err := pg.FetchTrades(context.Background(), TradeQuery{})
+	summaries := page.Trades
 	assert.NoError(t, err, "expected no error fetching trades, got %s", err)
 	assert.Equal(t, len(summaries), 1, "expected a single trade by ticker, got %v", summaries)
 
@@ -127,12 +138,13 @@ func TestMaxDailyVolume(t *testing.T) {
 }
 
 func TestGetByTicker(t *testing.T) {
+	skipWithoutDatabase(t)
+
 	expectedTrade := Trade{
 		Ticker:      ANOTHER_TICKER,
 		GrossAmount: 1,
 		Quantity:    10,
 		EntryTime:   time.Now(),
-		Date:        time.Now(),
 	}
 
 	trades := []Trade{
@@ -141,30 +153,29 @@ func TestGetByTicker(t *testing.T) {
 			GrossAmount: 1,
 			Quantity:    20,
 			EntryTime:   time.Now().AddDate(0, 0, -1),
-			Date:        time.Now().AddDate(0, 0, -1),
 		},
 		expectedTrade,
 	}
 
-	pg, err := NewPostgreSQL(TEST_DATABASE_URL)
+	pg, err := NewPostgreSQL(context.Background(), TEST_DATABASE_URL)
 	assert.NoError(t, err, "expected no error connecting to postgres, got %s", err)
 	defer func() {
-		if err := pg.DropTable(); err != nil {
+		if err := pg.DropTable(context.Background()); err != nil {
 			t.Errorf("expected no error dropping the table, got %s", err)
 		}
 		pg.Close()
 	}()
 
-	err = pg.CreateTable()
+	err = pg.CreateTable(context.Background())
 	assert.NoError(t, err, "expected no error creating table in postgres, got %s", err)
 
-	err = pg.InsertMany(trades)
+	err = pg.InsertMany(context.Background(), trades)
 	assert.NoError(t, err, "expected no error inserting trades, got %s", err)
 
-	err = pg.PostLoad()
+	err = pg.PostLoad(context.Background())
 	assert.NoError(t, err, "expected no error creating views in postgres, got %s", err)
 
-	summary, err := pg.GetTrade(ANOTHER_TICKER, "")
+	summary, err := pg.GetTrade(context.Background(), ANOTHER_TICKER, "", "")
 	assert.NoError(t, err, "expected no error getting summary, got %s", err)
 
 	assert.Equal(t, summary.Ticker, expectedTrade.Ticker, "expected summary ticker to be %v, got %v", expectedTrade.Ticker, summary.Ticker)
@@ -173,12 +184,13 @@ func TestGetByTicker(t *testing.T) {
 }
 
 func TestGetByTickerAndDate(t *testing.T) {
+	skipWithoutDatabase(t)
+
 	expectedTrade := Trade{
 		Ticker:      TICKER,
 		GrossAmount: 0.5,
 		Quantity:    1,
 		EntryTime:   time.Now(),
-		Date:        time.Now(),
 	}
 
 	trades := []Trade{
@@ -187,37 +199,35 @@ func TestGetByTickerAndDate(t *testing.T) {
 			GrossAmount: 1,
 			Quantity:    10,
 			EntryTime:   time.Now(),
-			Date:        time.Now(),
 		},
 		{
 			Ticker:      TICKER,
 			GrossAmount: 1,
 			Quantity:    20,
 			EntryTime:   time.Now().AddDate(0, 0, -1),
-			Date:        time.Now().AddDate(0, 0, -1),
 		},
 		expectedTrade,
 	}
 
-	pg, err := NewPostgreSQL(TEST_DATABASE_URL)
+	pg, err := NewPostgreSQL(context.Background(), TEST_DATABASE_URL)
 	assert.NoError(t, err, "expected no error connecting to postgres, got %s", err)
 	defer func() {
-		if err := pg.DropTable(); err != nil {
+		if err := pg.DropTable(context.Background()); err != nil {
 			t.Errorf("expected no error dropping the table, got %s", err)
 		}
 		pg.Close()
 	}()
 
-	err = pg.CreateTable()
+	err = pg.CreateTable(context.Background())
 	assert.NoError(t, err, "expected no error creating table in postgres, got %s", err)
 
-	err = pg.InsertMany(trades)
+	err = pg.InsertMany(context.Background(), trades)
 	assert.NoError(t, err, "expected no error inserting trades, got %s", err)
 
-	err = pg.PostLoad()
+	err = pg.PostLoad(context.Background())
 	assert.NoError(t, err, "expected no error creating views in postgres, got %s", err)
 
-	summary, err := pg.GetTrade(TICKER, time.Now().Format("2006-01-02"))
+	summary, err := pg.GetTrade(context.Background(), TICKER, time.Now().Format("2006-01-02"), "")
 	assert.NoError(t, err, "expected no error getting summary, got %s", err)
 
 	assert.Equal(t, summary.Ticker, expectedTrade.Ticker, "expected summary ticker to be %v, got %v", expectedTrade.Ticker, summary.Ticker)
@@ -226,12 +236,13 @@ func TestGetByTickerAndDate(t *testing.T) {
 }
 
 func TestFetchByDate(t *testing.T) {
+	skipWithoutDatabase(t)
+
 	expectedTrade1 := Trade{
 		Ticker:      ANOTHER_TICKER,
 		GrossAmount: 0.2,
 		Quantity:    1,
 		EntryTime:   time.Now(),
-		Date:        time.Now(),
 	}
 
 	expectedTrade2 := Trade{
@@ -239,7 +250,6 @@ func TestFetchByDate(t *testing.T) {
 		GrossAmount: 0.3,
 		Quantity:    1,
 		EntryTime:   time.Now(),
-		Date:        time.Now(),
 	}
 
 	expectedTrades := map[string]Trade{
@@ -253,7 +263,6 @@ func TestFetchByDate(t *testing.T) {
 			GrossAmount: 1,
 			Quantity:    10,
 			EntryTime:   time.Now().AddDate(0, 0, -1),
-			Date:        time.Now().AddDate(0, 0, -1),
 		},
 		expectedTrade1,
 		{
@@ -261,30 +270,30 @@ func TestFetchByDate(t *testing.T) {
 			GrossAmount: 1,
 			Quantity:    20,
 			EntryTime:   time.Now().AddDate(0, 0, -1),
-			Date:        time.Now().AddDate(0, 0, -1),
 		},
 		expectedTrade2,
 	}
 
-	pg, err := NewPostgreSQL(TEST_DATABASE_URL)
+	pg, err := NewPostgreSQL(context.Background(), TEST_DATABASE_URL)
 	assert.NoError(t, err, "expected no error connecting to postgres, got %s", err)
 	defer func() {
-		if err := pg.DropTable(); err != nil {
+		if err := pg.DropTable(context.Background()); err != nil {
 			t.Errorf("expected no error dropping the table, got %s", err)
 		}
 		pg.Close()
 	}()
 
-	err = pg.CreateTable()
+	err = pg.CreateTable(context.Background())
 	assert.NoError(t, err, "expected no error creating table in postgres, got %s", err)
 
-	err = pg.InsertMany(trades)
+	err = pg.InsertMany(context.Background(), trades)
 	assert.NoError(t, err, "expected no error inserting trades, got %s", err)
 
-	err = pg.PostLoad()
+	err = pg.PostLoad(context.Background())
 	assert.NoError(t, err, "expected no error creating views in postgres, got %s", err)
 
-	summaries, err := pg.FetchTrades(time.Now().Format("2006-01-02"))
+	page, err := pg.FetchTrades(context.Background(), TradeQuery{From: time.Now().Format("2006-01-02")})
+	summaries := page.Trades
 	assert.NoError(t, err, "expected no error fetching summaries, got %s", err)
 	assert.Equal(t, len(summaries), 2, "expected a single trade by ticker, got %v", summaries)
 
@@ -296,3 +305,423 @@ func TestFetchByDate(t *testing.T) {
 		assert.Equal(t, summary.MaxDailyVolume, expectedTrade.Quantity, "expected max daily volume to be %v, got %v", expectedTrade.Quantity, summary.MaxDailyVolume)
 	}
 }
+
+func TestGetCandles(t *testing.T) {
+	skipWithoutDatabase(t)
+
+	now := time.Now()
+
+	trades := []Trade{
+		{
+			Ticker:      TICKER,
+			GrossAmount: 2,
+			Quantity:    10,
+			EntryTime:   now.Add(-2 * time.Second),
+		},
+		{
+			Ticker:      TICKER,
+			GrossAmount: 3,
+			Quantity:    20,
+			EntryTime:   now.Add(-1 * time.Second),
+		},
+		{
+			Ticker:      TICKER,
+			GrossAmount: 1,
+			Quantity:    30,
+			EntryTime:   now,
+		},
+		{
+			Ticker:      ANOTHER_TICKER,
+			GrossAmount: 10,
+			Quantity:    10,
+			EntryTime:   now,
+		},
+	}
+
+	pg, err := NewPostgreSQL(context.Background(), TEST_DATABASE_URL)
+	assert.NoError(t, err, "expected no error connecting to postgres, got %s", err)
+	defer func() {
+		if err := pg.DropTable(context.Background()); err != nil {
+			t.Errorf("expected no error dropping the table, got %s", err)
+		}
+		pg.Close()
+	}()
+
+	err = pg.CreateTable(context.Background())
+	assert.NoError(t, err, "expected no error creating table in postgres, got %s", err)
+
+	err = pg.InsertMany(context.Background(), trades)
+	assert.NoError(t, err, "expected no error inserting trades, got %s", err)
+
+	err = pg.PostLoad(context.Background())
+	assert.NoError(t, err, "expected no error creating views in postgres, got %s", err)
+
+	candles, err := pg.GetCandles(context.Background(), TICKER, "1d", "", "")
+	assert.NoError(t, err, "expected no error getting candles, got %s", err)
+	assert.Equal(t, len(candles), 1, "expected a single daily candle, got %v", candles)
+
+	candle := candles[0]
+	assert.Equal(t, candle.Open, 2.0, "expected open to be %v, got %v", 2.0, candle.Open)
+	assert.Equal(t, candle.High, 3.0, "expected high to be %v, got %v", 3.0, candle.High)
+	assert.Equal(t, candle.Low, 1.0, "expected low to be %v, got %v", 1.0, candle.Low)
+	assert.Equal(t, candle.Close, 1.0, "expected close to be %v, got %v", 1.0, candle.Close)
+	assert.Equal(t, candle.Volume, int64(60), "expected volume to be %v, got %v", 60, candle.Volume)
+	assert.Equal(t, candle.TradeCount, int64(3), "expected trade count to be %v, got %v", 3, candle.TradeCount)
+}
+
+func TestCancelMany(t *testing.T) {
+	skipWithoutDatabase(t)
+
+	cancelledTrade := Trade{
+		Ticker:      TICKER,
+		GrossAmount: 2,
+		Quantity:    20,
+		EntryTime:   time.Now(),
+		TradeID:     2,
+	}
+
+	expectedTrade := Trade{
+		Ticker:      TICKER,
+		GrossAmount: 1,
+		Quantity:    10,
+		EntryTime:   time.Now(),
+		TradeID:     1,
+	}
+
+	cancellation := cancelledTrade
+	cancellation.UpdateAction = UpdateActionCancel
+
+	pg, err := NewPostgreSQL(context.Background(), TEST_DATABASE_URL)
+	assert.NoError(t, err, "expected no error connecting to postgres, got %s", err)
+	defer func() {
+		if err := pg.DropTable(context.Background()); err != nil {
+			t.Errorf("expected no error dropping the table, got %s", err)
+		}
+		pg.Close()
+	}()
+
+	err = pg.CreateTable(context.Background())
+	assert.NoError(t, err, "expected no error creating table in postgres, got %s", err)
+
+	err = pg.InsertMany(context.Background(), []Trade{cancelledTrade, expectedTrade})
+	assert.NoError(t, err, "expected no error inserting trades, got %s", err)
+
+	err = pg.CancelMany(context.Background(), []Trade{cancellation})
+	assert.NoError(t, err, "expected no error cancelling trades, got %s", err)
+
+	err = pg.PostLoad(context.Background())
+	assert.NoError(t, err, "expected no error creating views in postgres, got %s", err)
+
+	summary, err := pg.GetTrade(context.Background(), TICKER, "", "")
+	assert.NoError(t, err, "expected no error getting summary, got %s", err)
+
+	assert.Equal(t, summary.MaxRangeValue, expectedTrade.GrossAmount, "expected max range value to be %v, got %v", expectedTrade.GrossAmount, summary.MaxRangeValue)
+	assert.Equal(t, summary.MaxDailyVolume, expectedTrade.Quantity, "expected max daily volume to be %v, got %v", expectedTrade.Quantity, summary.MaxDailyVolume)
+}
+
+func TestInsertManyIsIdempotent(t *testing.T) {
+	skipWithoutDatabase(t)
+
+	now := time.Now()
+
+	trades := []Trade{
+		{
+			Ticker:      TICKER,
+			GrossAmount: 1,
+			Quantity:    10,
+			EntryTime:   now,
+			TradeID:     1,
+		},
+		{
+			Ticker:      TICKER,
+			GrossAmount: 1,
+			Quantity:    20,
+			EntryTime:   now,
+			TradeID:     2,
+		},
+	}
+
+	var expectedMaxDailyVolume int64 = 30
+
+	pg, err := NewPostgreSQL(context.Background(), TEST_DATABASE_URL)
+	assert.NoError(t, err, "expected no error connecting to postgres, got %s", err)
+	defer func() {
+		if err := pg.DropTable(context.Background()); err != nil {
+			t.Errorf("expected no error dropping the table, got %s", err)
+		}
+		pg.Close()
+	}()
+
+	err = pg.CreateTable(context.Background())
+	assert.NoError(t, err, "expected no error creating table in postgres, got %s", err)
+
+	for range 2 {
+		err = pg.InsertMany(context.Background(), trades)
+		assert.NoError(t, err, "expected no error inserting trades, got %s", err)
+	}
+
+	err = pg.PostLoad(context.Background())
+	assert.NoError(t, err, "expected no error creating views in postgres, got %s", err)
+
+	summary, err := pg.GetTrade(context.Background(), TICKER, "", "")
+	assert.NoError(t, err, "expected no error getting summary, got %s", err)
+
+	assert.Equal(t, summary.MaxDailyVolume, expectedMaxDailyVolume, "expected max daily volume to be %v, got %v", expectedMaxDailyVolume, summary.MaxDailyVolume)
+}
+
+func TestManifest(t *testing.T) {
+	skipWithoutDatabase(t)
+
+	expectedManifest := Manifest{
+		FileName:      "2024-07-01.zip",
+		SHA256:        "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855",
+		RowCount:      42,
+		ReferenceDate: time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC),
+		Status:        ManifestLoaded,
+		StartedAt:     time.Now().Add(-time.Minute).Truncate(time.Microsecond),
+		FinishedAt:    time.Now().Truncate(time.Microsecond),
+	}
+
+	pg, err := NewPostgreSQL(context.Background(), TEST_DATABASE_URL)
+	assert.NoError(t, err, "expected no error connecting to postgres, got %s", err)
+	defer func() {
+		if err := pg.DropTable(context.Background()); err != nil {
+			t.Errorf("expected no error dropping the table, got %s", err)
+		}
+		pg.Close()
+	}()
+
+	err = pg.CreateTable(context.Background())
+	assert.NoError(t, err, "expected no error creating table in postgres, got %s", err)
+
+	err = pg.PostLoad(context.Background())
+	assert.NoError(t, err, "expected no error creating views in postgres, got %s", err)
+
+	manifest, err := pg.GetManifest(context.Background(), expectedManifest.FileName)
+	assert.NoError(t, err, "expected no error getting manifest, got %s", err)
+	assert.Equal(t, manifest.Status, "", "expected a file never loaded to have no status, got %v", manifest.Status)
+
+	err = pg.SaveManifest(context.Background(), expectedManifest)
+	assert.NoError(t, err, "expected no error saving manifest, got %s", err)
+
+	manifest, err = pg.GetManifest(context.Background(), expectedManifest.FileName)
+	assert.NoError(t, err, "expected no error getting manifest, got %s", err)
+
+	assert.Equal(t, manifest.SHA256, expectedManifest.SHA256, "expected sha256 to be %v, got %v", expectedManifest.SHA256, manifest.SHA256)
+	assert.Equal(t, manifest.RowCount, expectedManifest.RowCount, "expected row count to be %v, got %v", expectedManifest.RowCount, manifest.RowCount)
+	assert.Equal(t, manifest.Status, expectedManifest.Status, "expected status to be %v, got %v", expectedManifest.Status, manifest.Status)
+	assert.True(t, manifest.FinishedAt.Equal(expectedManifest.FinishedAt), "expected finished at to be %v, got %v", expectedManifest.FinishedAt, manifest.FinishedAt)
+}
+
+// sliceSource is a TradeSource over a slice of trades.
+type sliceSource struct {
+	trades []Trade
+	next   int
+}
+
+func (s *sliceSource) Next() bool {
+	s.next++
+	return s.next <= len(s.trades)
+}
+
+func (s *sliceSource) Trade() Trade { return s.trades[s.next-1] }
+
+func (s *sliceSource) Err() error { return nil }
+
+func TestCopyMany(t *testing.T) {
+	skipWithoutDatabase(t)
+
+	now := time.Now()
+
+	trades := []Trade{
+		{
+			Ticker:      TICKER,
+			GrossAmount: 1,
+			Quantity:    10,
+			EntryTime:   now,
+			TradeID:     1,
+		},
+		{
+			Ticker:      TICKER,
+			GrossAmount: 2,
+			Quantity:    20,
+			EntryTime:   now,
+			TradeID:     2,
+		},
+	}
+
+	var expectedMaxDailyVolume int64 = 30
+
+	pg, err := NewPostgreSQL(context.Background(), TEST_DATABASE_URL)
+	assert.NoError(t, err, "expected no error connecting to postgres, got %s", err)
+	defer func() {
+		if err := pg.DropTable(context.Background()); err != nil {
+			t.Errorf("expected no error dropping the table, got %s", err)
+		}
+		pg.Close()
+	}()
+
+	err = pg.CreateTable(context.Background())
+	assert.NoError(t, err, "expected no error creating table in postgres, got %s", err)
+
+	for range 2 {
+		n, err := pg.CopyMany(context.Background(), &sliceSource{trades: trades})
+		assert.NoError(t, err, "expected no error copying trades, got %s", err)
+		assert.Equal(t, n, int64(len(trades)), "expected %v trades copied, got %v", len(trades), n)
+	}
+
+	err = pg.PostLoad(context.Background())
+	assert.NoError(t, err, "expected no error creating views in postgres, got %s", err)
+
+	summary, err := pg.GetTrade(context.Background(), TICKER, "", "")
+	assert.NoError(t, err, "expected no error getting summary, got %s", err)
+
+	assert.Equal(t, summary.MaxRangeValue, 2.0, "expected max range value to be %v, got %v", 2.0, summary.MaxRangeValue)
+	assert.Equal(t, summary.MaxDailyVolume, expectedMaxDailyVolume, "expected max daily volume to be %v, got %v", expectedMaxDailyVolume, summary.MaxDailyVolume)
+}
+
+func TestPostgreSQL(t *testing.T) {
+	skipWithoutDatabase(t)
+
+	testDB(t, func(t *testing.T) DB {
+		pg, err := NewPostgreSQL(context.Background(), TEST_DATABASE_URL)
+		if err != nil {
+			t.Fatalf("expected no error connecting to postgres, got %s", err)
+		}
+
+		t.Cleanup(func() {
+			if err := pg.DropTable(context.Background()); err != nil {
+				t.Errorf("expected no error dropping the table, got %s", err)
+			}
+			pg.Close()
+		})
+
+		if err := pg.CreateTable(context.Background()); err != nil {
+			t.Fatalf("expected no error creating table in postgres, got %s", err)
+		}
+
+		return &pg
+	})
+}
+
+func TestPostgreSQLMigrations(t *testing.T) {
+	skipWithoutDatabase(t)
+
+	pg, err := NewPostgreSQL(context.Background(), TEST_DATABASE_URL)
+	if err != nil {
+		t.Fatalf("expected no error connecting to postgres, got %s", err)
+	}
+	defer func() {
+		if err := pg.DropTable(context.Background()); err != nil {
+			t.Errorf("expected no error dropping the table, got %s", err)
+		}
+		pg.Close()
+	}()
+
+	if err := pg.CreateTable(context.Background()); err != nil {
+		t.Fatalf("expected no error creating table in postgres, got %s", err)
+	}
+
+	testMigrations(t, &pg)
+}
+
+func TestRetentionStart(t *testing.T) {
+	loc, err := loadLocation()
+	if err != nil {
+		t.Fatalf("expected no error loading location, got %s", err)
+	}
+
+	midnight := time.Date(2024, 7, 2, 0, 0, 0, 0, loc)
+
+	for _, c := range []struct {
+		horizon  time.Time
+		expected time.Time
+	}{
+		{midnight, midnight},
+		{midnight.Add(-time.Minute), midnight},
+		{time.Date(2024, 7, 1, 3, 0, 1, 0, time.UTC), midnight},
+	} {
+		start := retentionStart(c.horizon, loc)
+		assert.True(t, start.Equal(c.expected), "expected the retention of %s to start at %s, got %s", c.horizon, c.expected, start)
+	}
+}
+
+func TestPostgreSQLRefreshWithRetention(t *testing.T) {
+	skipWithoutDatabase(t)
+
+	pg, err := NewPostgreSQL(context.Background(), TEST_DATABASE_URL)
+	if err != nil {
+		t.Fatalf("expected no error connecting to postgres, got %s", err)
+	}
+	defer func() {
+		if err := pg.DropTable(context.Background()); err != nil {
+			t.Errorf("expected no error dropping the table, got %s", err)
+		}
+		pg.Close()
+	}()
+
+	if err := pg.CreateTable(context.Background()); err != nil {
+		t.Fatalf("expected no error creating table in postgres, got %s", err)
+	}
+
+	now := time.Now()
+
+	err = pg.InsertMany(context.Background(), []Trade{
+		{Ticker: TICKER, GrossAmount: 1, Quantity: 10, EntryTime: now.AddDate(0, 0, -10), TradeID: 1},
+		{Ticker: TICKER, GrossAmount: 2, Quantity: 20, EntryTime: now, TradeID: 1},
+	})
+	assert.NoError(t, err, "expected no error inserting trades, got %s", err)
+
+	err = pg.PostLoad(context.Background())
+	assert.NoError(t, err, "expected no error building summaries, got %s", err)
+
+	err = pg.SetRetention(context.Background(), "3 days")
+	assert.NoError(t, err, "expected no error setting retention, got %s", err)
+	defer pg.SetRetention(context.Background(), "")
+
+	today := now.Format(time.DateOnly)
+	old := now.AddDate(0, 0, -10).Format(time.DateOnly)
+
+	// from older than the retention, the window starts at the first retained
+	// day; a window entirely before it, or a single day, is not too small
+	for _, window := range [][2]string{{old, today}, {"", ""}, {old, old}, {today, today}} {
+		err = pg.Refresh(context.Background(), window[0], window[1])
+		assert.NoError(t, err, "expected no error refreshing from %q to %q, got %s", window[0], window[1], err)
+	}
+
+	days, err := pg.GetTradeDays(context.Background(), TICKER, "", "")
+	assert.NoError(t, err, "expected no error getting days, got %s", err)
+	assert.Equal(t, len(days), 2, "expected the day before the retention to be kept, got %v", days)
+}
+
+func TestPostgreSQLPolicies(t *testing.T) {
+	skipWithoutDatabase(t)
+
+	pg, err := NewPostgreSQL(context.Background(), TEST_DATABASE_URL)
+	if err != nil {
+		t.Fatalf("expected no error connecting to postgres, got %s", err)
+	}
+	defer func() {
+		if err := pg.DropTable(context.Background()); err != nil {
+			t.Errorf("expected no error dropping the table, got %s", err)
+		}
+		pg.Close()
+	}()
+
+	if err := pg.CreateTable(context.Background()); err != nil {
+		t.Fatalf("expected no error creating table in postgres, got %s", err)
+	}
+
+	for _, after := range []string{"7 days", "30 days", ""} {
+		err := pg.SetCompression(context.Background(), after)
+		assert.NoError(t, err, "expected no error setting compression after %q, got %s", after, err)
+	}
+
+	for _, keep := range []string{"1 year", "2 years", ""} {
+		err := pg.SetRetention(context.Background(), keep)
+		assert.NoError(t, err, "expected no error setting retention of %q, got %s", keep, err)
+	}
+
+	err = pg.SetRetention(context.Background(), "forever")
+	assert.Error(t, err, "expected an error setting retention with an invalid interval")
+}
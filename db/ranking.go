@@ -0,0 +1,97 @@
+package db
+
+import "fmt"
+
+// Metrics tickers can be ranked by.
+const (
+	RankVolume          = "volume"
+	RankFinancialVolume = "financial_volume"
+	RankTrades          = "trades"
+	RankReturn          = "return"
+)
+
+// rankingMetrics are the SQL expressions computing each metric out of the
+// days of trade_summary grouped by ticker. The PostgreSQL ones read the days
+// of SUMMARY_DAYS, leaving out tickers with days without statistics, as
+// SUMMARY_STATS does, and the SQLite ones read the days selected with
+// SQLITE_SUMMARY_DAYS.
+var rankingMetrics = map[string]struct {
+	postgres string
+	sqlite   string
+}{
+	RankVolume: {"SUM(total_quantity)", "SUM(total_quantity)"},
+	RankFinancialVolume: {
+		"CASE WHEN COUNT(trade_count) = COUNT(*) THEN SUM(financial_volume) END",
+		"SUM(financial_volume)",
+	},
+	RankTrades: {
+		"CASE WHEN COUNT(trade_count) = COUNT(*) THEN SUM(trade_count) END",
+		"SUM(trade_count)",
+	},
+	RankReturn: {
+		"CASE WHEN COUNT(trade_count) = COUNT(*) THEN last(close_price, date) / NULLIF(first(open_price, date), 0) - 1 END",
+		"MAX(last_close) / NULLIF(MAX(first_open), 0) - 1",
+	},
+}
+
+// RankingQuery ranks tickers by a metric over the days from From to To,
+// inclusive. Empty dates leave the range open.
+type RankingQuery struct {
+	From   string
+	To     string
+	Metric string
+	// Desc ranks the highest values first, as the top of a ranking.
+	Desc bool
+	// Limit is the number of tickers ranked.
+	Limit int
+}
+
+// Rank is the value of the metric of a ranked ticker.
+type Rank struct {
+	Ticker string  `json:"ticker"`
+	Value  float64 `json:"value"`
+}
+
+// check validates the query.
+func (q RankingQuery) check() error {
+	if _, ok := rankingMetrics[q.Metric]; !ok {
+		return fmt.Errorf("%w: ranking metric %q", ErrInvalidArgument, q.Metric)
+	}
+
+	if q.Limit < 1 {
+		return fmt.Errorf("%w: limit %d", ErrInvalidArgument, q.Limit)
+	}
+
+	return checkDates(q.From, q.To)
+}
+
+// direction returns the order of the values of FETCH_RANKING.
+func (q RankingQuery) direction() string {
+	if q.Desc {
+		return "DESC"
+	}
+
+	return "ASC"
+}
+
+// value returns the metric of a summary, or nil when it cannot be computed.
+func (q RankingQuery) value(t TradeSummary) *float64 {
+	switch q.Metric {
+	case RankVolume:
+		if t.Volume != nil {
+			return ptr(float64(*t.Volume))
+		}
+	case RankFinancialVolume:
+		return t.FinancialVolume
+	case RankTrades:
+		if t.TradeCount != nil {
+			return ptr(float64(*t.TradeCount))
+		}
+	case RankReturn:
+		if t.Open != nil && t.Close != nil && *t.Open != 0 {
+			return ptr(*t.Close / *t.Open - 1)
+		}
+	}
+
+	return nil
+}
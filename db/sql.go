@@ -1,54 +1,315 @@
 package db
 
-const FETCH_ALL = `
-    SELECT 
-      ticker, 
-      MAX(max_range_value) AS max_range_value, 
-      MAX(total_quantity) AS max_daily_volume 
-    FROM 
-      trade_summary 
-    GROUP BY 
-      ticker;
+// SUMMARY_DAYS joins the days of trade_summary to their statistics. Days
+// summarized before trade_summary_stats existed, and whose trades were
+// dropped since, have no statistics.
+const SUMMARY_DAYS = `trade_summary LEFT JOIN trade_summary_stats USING (date, ticker)`
+
+// SUMMARY_STATS aggregates the statistics of TradeSummary out of the days of
+// SUMMARY_DAYS grouped by a query. Those summing or picking days are NULL
+// when any of the days has no statistics, rather than computed out of part
+// of the period.
+const SUMMARY_STATS = `
+        CASE WHEN COUNT(trade_count) = COUNT(*) THEN first(open_price, date) END AS open_price,
+        CASE WHEN COUNT(trade_count) = COUNT(*) THEN last(close_price, date) END AS close_price,
+        CASE WHEN COUNT(trade_count) = COUNT(*) THEN MIN(min_range_value) END AS min_range_value,
+        CASE WHEN COUNT(trade_count) = COUNT(*) THEN SUM(financial_volume) / NULLIF(SUM(total_quantity), 0) END AS vwap,
+        SUM(total_quantity)::bigint AS volume,
+        CASE WHEN COUNT(trade_count) = COUNT(*) THEN SUM(financial_volume) END AS financial_volume,
+        CASE WHEN COUNT(trade_count) = COUNT(*) THEN SUM(trade_count)::bigint END AS trade_count,
+        CASE WHEN COUNT(trade_count) = COUNT(*) THEN SUM(total_quantity)::numeric / NULLIF(SUM(trade_count), 0) END AS avg_trade_size`
+
+// FETCH_TRADES_PAGE summarizes by ticker the days from $1 to $2, inclusive,
+// for the tickers matching the LIKE pattern $3. NULLs leave these filters
+// open. Pages are ordered by the column %[1]s and then ticker, in the
+// direction %[2]s, and start after the cursor ($4, $5), compared with %[3]s
+// as the type %[4]s. $6 limits the page size.
+const FETCH_TRADES_PAGE = `
+    SELECT
+      ticker,
+      max_range_value,
+      max_daily_volume,
+      open_price,
+      close_price,
+      min_range_value,
+      vwap,
+      volume,
+      financial_volume,
+      trade_count,
+      avg_trade_size
+    FROM (
+      SELECT 
+        ticker, 
+        MAX(max_range_value) AS max_range_value, 
+        MAX(total_quantity) AS max_daily_volume,
+        ` + SUMMARY_STATS + `
+      FROM 
+        ` + SUMMARY_DAYS + `
+      WHERE ($1::date IS NULL OR date >= $1::date::timestamp AT TIME ZONE 'America/Sao_Paulo')
+        AND ($2::date IS NULL OR date < ($2::date + 1)::timestamp AT TIME ZONE 'America/Sao_Paulo')
+        AND ($3::text IS NULL OR ticker LIKE $3)
+      GROUP BY 
+        ticker
+    ) s
+    WHERE $5::text IS NULL OR (%[1]s, ticker) %[3]s ($4::%[4]s, $5::text)
+    ORDER BY
+      %[1]s %[2]s, ticker %[2]s
+    LIMIT $6;
 `
 
-const FETCH_BY_DATE = ` 
-    SELECT 
-      ticker, 
-      MAX(max_range_value) AS max_range_value, 
-      MAX(total_quantity) AS max_daily_volume 
-    FROM 
-      trade_summary 
-    WHERE date >= $1
-    GROUP BY 
-      ticker;
+// FETCH_RANKING ranks tickers by the metric %[1]s over the days from $1 to
+// $2, inclusive, in the direction %[2]s, leaving out tickers the metric
+// cannot be computed for. NULL dates leave the range open. $3 limits the
+// number of tickers.
+const FETCH_RANKING = `
+    SELECT
+      ticker,
+      value
+    FROM (
+      SELECT
+        ticker,
+        (%[1]s)::float8 AS value
+      FROM
+        ` + SUMMARY_DAYS + `
+      WHERE ($1::date IS NULL OR date >= $1::date::timestamp AT TIME ZONE 'America/Sao_Paulo')
+        AND ($2::date IS NULL OR date < ($2::date + 1)::timestamp AT TIME ZONE 'America/Sao_Paulo')
+      GROUP BY
+        ticker
+    ) r
+    WHERE value IS NOT NULL
+    ORDER BY
+      value %[2]s, ticker
+    LIMIT $3;
 `
 
-const GET_BY_TICKER = ` 
+// GET_TRADE summarizes the ticker $1 for the days from $2 to $3, inclusive.
+// NULL dates leave the range open.
+const GET_TRADE = ` 
     SELECT 
       ticker, 
       MAX(max_range_value) AS max_range_value, 
-      MAX(total_quantity) AS max_daily_volume 
+      MAX(total_quantity) AS max_daily_volume,
+      ` + SUMMARY_STATS + `
     FROM 
-      trade_summary 
+      ` + SUMMARY_DAYS + `
     WHERE ticker = $1
+      AND ($2::date IS NULL OR date >= $2::date::timestamp AT TIME ZONE 'America/Sao_Paulo')
+      AND ($3::date IS NULL OR date < ($3::date + 1)::timestamp AT TIME ZONE 'America/Sao_Paulo')
     GROUP BY 
       ticker;
 `
 
-const GET_BY_TICKER_AND_DATE = ` 
-    SELECT 
-      ticker, 
-      MAX(max_range_value) AS max_range_value, 
-      MAX(total_quantity) AS max_daily_volume 
-    FROM 
-      trade_summary 
-    WHERE ticker = $1 AND date >= $2
-    GROUP BY 
-      ticker;
+// GET_TRADE_DAYS returns the days of the ticker $1 from $2 to $3, inclusive,
+// in date order. NULL dates leave the range open.
+const GET_TRADE_DAYS = `
+    SELECT
+      to_char(date AT TIME ZONE 'America/Sao_Paulo', 'YYYY-MM-DD') AS day,
+      max_range_value,
+      total_quantity,
+      open_price,
+      close_price,
+      min_range_value,
+      financial_volume / NULLIF(total_quantity, 0) AS vwap,
+      financial_volume,
+      trade_count,
+      total_quantity::numeric / NULLIF(trade_count, 0) AS avg_trade_size
+    FROM
+      ` + SUMMARY_DAYS + `
+    WHERE ticker = $1
+      AND ($2::date IS NULL OR date >= $2::date::timestamp AT TIME ZONE 'America/Sao_Paulo')
+      AND ($3::date IS NULL OR date < ($3::date + 1)::timestamp AT TIME ZONE 'America/Sao_Paulo')
+    ORDER BY
+      date;
+`
+
+// FETCH_TRADE_DAYS is GET_TRADE_DAYS for the tickers in the array $1,
+// ordered by ticker and date.
+const FETCH_TRADE_DAYS = `
+    SELECT
+      ticker,
+      to_char(date AT TIME ZONE 'America/Sao_Paulo', 'YYYY-MM-DD') AS day,
+      max_range_value,
+      total_quantity,
+      open_price,
+      close_price,
+      min_range_value,
+      financial_volume / NULLIF(total_quantity, 0) AS vwap,
+      financial_volume,
+      trade_count,
+      total_quantity::numeric / NULLIF(trade_count, 0) AS avg_trade_size
+    FROM
+      ` + SUMMARY_DAYS + `
+    WHERE ticker = ANY($1::text[])
+      AND ($2::date IS NULL OR date >= $2::date::timestamp AT TIME ZONE 'America/Sao_Paulo')
+      AND ($3::date IS NULL OR date < ($3::date + 1)::timestamp AT TIME ZONE 'America/Sao_Paulo')
+    ORDER BY
+      ticker, date;
 `
+
+const GET_CANDLES = `
+    SELECT
+      time_bucket($2::interval, entry_time, 'America/Sao_Paulo') AS bucket,
+      first(gross_amount, entry_time) AS open,
+      MAX(gross_amount) AS high,
+      MIN(gross_amount) AS low,
+      last(gross_amount, entry_time) AS close,
+      SUM(quantity) AS volume,
+      COUNT(*) AS trade_count
+    FROM
+      trade
+    WHERE ticker = $1
+      AND NOT cancelled
+      AND ($3::date IS NULL OR entry_time >= $3::date::timestamp AT TIME ZONE 'America/Sao_Paulo')
+      AND ($4::date IS NULL OR entry_time < ($4::date + 1)::timestamp AT TIME ZONE 'America/Sao_Paulo')
+    GROUP BY
+      bucket
+    ORDER BY
+      bucket;
+`
+
+// GET_TICKS returns the trades of the ticker $1 entered from $2, inclusive,
+// to $3, exclusive, in time order.
+const GET_TICKS = `
+    SELECT
+      entry_time,
+      gross_amount,
+      quantity,
+      COALESCE(trade_id, 0)
+    FROM
+      trade
+    WHERE ticker = $1
+      AND NOT cancelled
+      AND entry_time >= $2
+      AND entry_time < $3
+    ORDER BY
+      entry_time, trade_id;
+`
+
+// DELETE_REPLACED_TRADE deletes the trade with the ID $2 of the ticker $1
+// traded on the same B3 session as $3 at another time, so that CREATE_TRADE
+// replaces it: trade_key has to include entry_time, so it cannot tell apart
+// a trade corrected to another time by itself.
+const DELETE_REPLACED_TRADE = `
+    DELETE FROM trade
+    WHERE ticker = $1
+      AND trade_id = $2
+      AND entry_time >= time_bucket('1 day', $3::timestamptz, 'America/Sao_Paulo')
+      AND entry_time < time_bucket('1 day', $3::timestamptz, 'America/Sao_Paulo') + INTERVAL '1 day'
+      AND entry_time <> $3::timestamptz
+`
+
+// CREATE_TRADE upserts a trade by trade_key. A trade stored again, as a
+// correction or by loading its file again, is no longer cancelled: the
+// cancellations of a file are applied after its trades.
 const CREATE_TRADE = `
-    INSERT INTO trade (ticker, gross_amount, quantity, entry_time, date)
-    VALUES ($1, $2, $3, $4, $5)
+    INSERT INTO trade (
+        ticker, gross_amount, quantity, entry_time, reference_date,
+        update_action, trade_id, session_type, buyer_code, seller_code
+    )
+    VALUES ($1, $2, $3, $4, $5, $6, NULLIF($7, 0), $8, NULLIF($9, 0), NULLIF($10, 0))
+    ON CONFLICT (ticker, trade_id, entry_time) DO UPDATE SET
+        gross_amount = EXCLUDED.gross_amount,
+        quantity = EXCLUDED.quantity,
+        reference_date = EXCLUDED.reference_date,
+        update_action = EXCLUDED.update_action,
+        session_type = EXCLUDED.session_type,
+        buyer_code = EXCLUDED.buyer_code,
+        seller_code = EXCLUDED.seller_code,
+        cancelled = FALSE
+`
+
+const CREATE_TRADE_STAGING = `
+    CREATE TEMPORARY TABLE trade_staging (LIKE trade INCLUDING DEFAULTS) ON COMMIT DROP;
+`
+
+// ANALYZE_TRADE_STAGING collects statistics of the staging table, which
+// temporary tables never get from autovacuum, so the statements joining it to
+// trade are planned for its actual size.
+const ANALYZE_TRADE_STAGING = `
+    ANALYZE trade_staging;
+`
+
+// DELETE_STAGING_DUPLICATES keeps only the last trade copied into the staging
+// table for each ID, ticker and B3 session, as inserting them in order with
+// DELETE_REPLACED_TRADE and CREATE_TRADE would. A single sort numbers the
+// trades, rather than joining the table to itself.
+const DELETE_STAGING_DUPLICATES = `
+    DELETE FROM trade_staging
+    WHERE ctid IN (
+        SELECT ctid
+        FROM (
+            SELECT
+                ctid,
+                ROW_NUMBER() OVER (
+                    PARTITION BY ticker, trade_id, time_bucket('1 day', entry_time, 'America/Sao_Paulo')
+                    ORDER BY ctid DESC
+                ) AS n
+            FROM
+                trade_staging
+            WHERE
+                trade_id IS NOT NULL
+        ) d
+        WHERE n > 1
+    )
+`
+
+// DELETE_REPLACED_STAGING is DELETE_REPLACED_TRADE for the trades in the
+// staging table.
+const DELETE_REPLACED_STAGING = `
+    DELETE FROM trade t
+    USING trade_staging s
+    WHERE t.ticker = s.ticker
+      AND t.trade_id = s.trade_id
+      AND t.entry_time >= time_bucket('1 day', s.entry_time, 'America/Sao_Paulo')
+      AND t.entry_time < time_bucket('1 day', s.entry_time, 'America/Sao_Paulo') + INTERVAL '1 day'
+      AND t.entry_time <> s.entry_time
+`
+
+// MERGE_TRADE_STAGING moves trades copied into the staging table to trade
+// with the same upsert semantics as CREATE_TRADE, once
+// DELETE_STAGING_DUPLICATES and DELETE_REPLACED_STAGING ran.
+const MERGE_TRADE_STAGING = `
+    INSERT INTO trade (
+        ticker, gross_amount, quantity, entry_time, reference_date,
+        update_action, trade_id, session_type, buyer_code, seller_code
+    )
+    SELECT
+        ticker, gross_amount, quantity, entry_time, reference_date,
+        update_action, trade_id, session_type, buyer_code, seller_code
+    FROM
+        trade_staging
+    ON CONFLICT (ticker, trade_id, entry_time) DO UPDATE SET
+        gross_amount = EXCLUDED.gross_amount,
+        quantity = EXCLUDED.quantity,
+        reference_date = EXCLUDED.reference_date,
+        update_action = EXCLUDED.update_action,
+        session_type = EXCLUDED.session_type,
+        buyer_code = EXCLUDED.buyer_code,
+        seller_code = EXCLUDED.seller_code,
+        cancelled = FALSE
+`
+
+// CANCEL_TRADE marks the trade with the given ID traded on the same B3 session
+// as the cancellation row, so it is left out of summaries and candles.
+const CANCEL_TRADE = `
+    UPDATE trade
+    SET cancelled = TRUE
+    WHERE ticker = $1
+      AND trade_id = $2
+      AND entry_time >= time_bucket('1 day', $3::timestamptz, 'America/Sao_Paulo')
+      AND entry_time < time_bucket('1 day', $3::timestamptz, 'America/Sao_Paulo') + INTERVAL '1 day'
+`
+
+// MIGRATE_LEGACY_TRADE moves a trade table created before entry_time was a
+// full timestamp out of the way, so CREATE_TABLE can build the new one.
+const MIGRATE_LEGACY_TRADE = `
+    DO $$
+    BEGIN
+        IF EXISTS (SELECT 1 FROM information_schema.columns WHERE table_name = 'trade' AND table_schema = 'public' AND column_name = 'date') THEN
+            DROP MATERIALIZED VIEW IF EXISTS trade_summary;
+            ALTER TABLE trade RENAME TO trade_legacy;
+        END IF;
+    END $$;
 `
 
 const CREATE_TABLE = `
@@ -56,39 +317,349 @@ const CREATE_TABLE = `
         ticker TEXT NOT NULL, 
         gross_amount NUMERIC(10, 3),
         quantity INT NOT NULL,
-        entry_time TIME WITH TIME ZONE, 
-        date DATE
+        entry_time TIMESTAMPTZ NOT NULL
     );
 `
+
+// ADD_TRADE_COLUMNS adds the columns ingested from the B3 file to trade
+// tables created when only ticker, price, quantity and time were kept.
+const ADD_TRADE_COLUMNS = `
+    ALTER TABLE trade
+        ADD COLUMN IF NOT EXISTS reference_date DATE,
+        ADD COLUMN IF NOT EXISTS update_action SMALLINT,
+        ADD COLUMN IF NOT EXISTS trade_id BIGINT,
+        ADD COLUMN IF NOT EXISTS session_type SMALLINT,
+        ADD COLUMN IF NOT EXISTS buyer_code INT,
+        ADD COLUMN IF NOT EXISTS seller_code INT,
+        ADD COLUMN IF NOT EXISTS cancelled BOOLEAN NOT NULL DEFAULT FALSE;
+`
+
+const DROP_TRADE_COLUMNS = `
+    ALTER TABLE trade
+        DROP COLUMN IF EXISTS reference_date,
+        DROP COLUMN IF EXISTS update_action,
+        DROP COLUMN IF EXISTS trade_id,
+        DROP COLUMN IF EXISTS session_type,
+        DROP COLUMN IF EXISTS buyer_code,
+        DROP COLUMN IF EXISTS seller_code,
+        DROP COLUMN IF EXISTS cancelled;
+`
+
 const CREATE_HYPERTABLE = `
+    SELECT create_hypertable('trade', 'entry_time', if_not_exists => TRUE);
+`
+
+// COPY_LEGACY_TRADE combines the legacy date and entry_time columns, whose
+// time of day is the wall clock time at B3, into the new entry_time column.
+const COPY_LEGACY_TRADE = `
     DO $$
     BEGIN
-        IF NOT EXISTS (SELECT 1 FROM information_schema.tables WHERE table_name = 'trade' AND table_schema = 'public') THEN
-            SELECT create_hypertable('trade', 'date');
+        IF EXISTS (SELECT 1 FROM information_schema.tables WHERE table_name = 'trade_legacy' AND table_schema = 'public') THEN
+            INSERT INTO trade (ticker, gross_amount, quantity, entry_time)
+            SELECT ticker, gross_amount, quantity, (date + entry_time::time) AT TIME ZONE 'America/Sao_Paulo'
+            FROM trade_legacy;
+
+            DROP TABLE trade_legacy;
         END IF;
     END $$;
 `
-const CREATE_MATERIALIZED_VIEW = `
-    CREATE MATERIALIZED VIEW IF NOT EXISTS trade_summary 
+
+// CREATE_TRADE_KEY creates the key trades are upserted by: B3 trade IDs are
+// unique per ticker and session, but entry_time has to be part of any unique
+// index on the hypertable, so the other trades of the session with the same
+// ID are deleted by DELETE_REPLACED_TRADE. Duplicates loaded before the key
+// existed are removed first.
+const CREATE_TRADE_KEY = `
+    DO $$
+    BEGIN
+        IF NOT EXISTS (SELECT 1 FROM pg_indexes WHERE tablename = 'trade' AND schemaname = 'public' AND indexname = 'trade_key') THEN
+            DELETE FROM trade t
+            USING (
+                SELECT
+                    tableoid,
+                    ctid,
+                    ROW_NUMBER() OVER (PARTITION BY ticker, trade_id, entry_time) AS n
+                FROM
+                    trade
+                WHERE
+                    trade_id IS NOT NULL
+            ) d
+            WHERE t.tableoid = d.tableoid AND t.ctid = d.ctid AND d.n > 1;
+
+            CREATE UNIQUE INDEX trade_key ON trade (ticker, trade_id, entry_time);
+        END IF;
+    END $$;
+`
+
+const DROP_TRADE_KEY = `
+    DROP INDEX IF EXISTS trade_key;
+`
+
+// DEDUPLICATE_TRADE_SESSIONS removes the trades loaded before
+// DELETE_REPLACED_TRADE existed that share their ID, ticker and B3 session
+// with a later one, keeping the latest. Trades without an ID, stored by
+// versions that did not read it, cannot be told apart and are kept.
+const DEDUPLICATE_TRADE_SESSIONS = `
+    DELETE FROM trade t
+    USING (
+        SELECT
+            tableoid,
+            ctid,
+            ROW_NUMBER() OVER (
+                PARTITION BY ticker, trade_id, time_bucket('1 day', entry_time, 'America/Sao_Paulo')
+                ORDER BY entry_time DESC
+            ) AS n
+        FROM
+            trade
+        WHERE
+            trade_id IS NOT NULL
+    ) d
+    WHERE t.tableoid = d.tableoid AND t.ctid = d.ctid AND d.n > 1;
+`
+
+const CREATE_LOAD_MANIFEST = `
+    CREATE TABLE IF NOT EXISTS load_manifest (
+        file_name TEXT PRIMARY KEY,
+        sha256 TEXT NOT NULL,
+        row_count BIGINT NOT NULL DEFAULT 0,
+        reference_date DATE,
+        status TEXT NOT NULL,
+        started_at TIMESTAMPTZ NOT NULL,
+        finished_at TIMESTAMPTZ
+    );
+`
+
+const GET_MANIFEST = `
+    SELECT
+      file_name,
+      sha256,
+      row_count,
+      reference_date,
+      status,
+      started_at,
+      finished_at
+    FROM
+      load_manifest
+    WHERE file_name = $1;
+`
+
+const SAVE_MANIFEST = `
+    INSERT INTO load_manifest (file_name, sha256, row_count, reference_date, status, started_at, finished_at)
+    VALUES ($1, $2, $3, $4, $5, $6, $7)
+    ON CONFLICT (file_name) DO UPDATE SET
+        sha256 = EXCLUDED.sha256,
+        row_count = EXCLUDED.row_count,
+        reference_date = EXCLUDED.reference_date,
+        status = EXCLUDED.status,
+        started_at = EXCLUDED.started_at,
+        finished_at = EXCLUDED.finished_at
+`
+
+// CREATE_CONTINUOUS_AGGREGATE creates trade_summary as a continuous aggregate.
+// It starts empty and is materialized by refreshes, while real time
+// aggregation serves days not materialized yet.
+const CREATE_CONTINUOUS_AGGREGATE = `
+    CREATE MATERIALIZED VIEW IF NOT EXISTS trade_summary
+    WITH (timescaledb.continuous, timescaledb.materialized_only = false)
     AS
     SELECT
-        time_bucket('1 day', date) AS date,
+        time_bucket('1 day', entry_time, 'America/Sao_Paulo') AS date,
         ticker,
         MAX(gross_amount) AS max_range_value,
         SUM(quantity) AS total_quantity
     FROM
         trade
+    WHERE
+        NOT cancelled
     GROUP BY
-        date, ticker;
+        date, ticker
+    WITH NO DATA;
+`
+
+// CREATE_TRADE_SUMMARY_STATS creates trade_summary_stats, the open, close
+// and minimum prices, financial volume and number of trades of each day of
+// trade_summary. It is a continuous aggregate of its own, rather than new
+// columns of trade_summary, since recreating trade_summary would lose the
+// days whose trades were already dropped by retention.
+const CREATE_TRADE_SUMMARY_STATS = `
+    CREATE MATERIALIZED VIEW IF NOT EXISTS trade_summary_stats
+    WITH (timescaledb.continuous, timescaledb.materialized_only = false)
+    AS
+    SELECT
+        time_bucket('1 day', entry_time, 'America/Sao_Paulo') AS date,
+        ticker,
+        first(gross_amount, entry_time) AS open_price,
+        last(gross_amount, entry_time) AS close_price,
+        MIN(gross_amount) AS min_range_value,
+        SUM(gross_amount * quantity) AS financial_volume,
+        COUNT(*) AS trade_count
+    FROM
+        trade
+    WHERE
+        NOT cancelled
+    GROUP BY
+        date, ticker
+    WITH NO DATA;
+`
+
+const CREATE_TRADE_SUMMARY_STATS_INDEXES = `
+    CREATE INDEX IF NOT EXISTS idx_trade_summary_stats_ticker_day ON trade_summary_stats (ticker, date);
+`
+
+// ADD_STATS_REFRESH_POLICY refreshes trade_summary_stats every hour over
+// the same window as the refresh policy of trade_summary, so that both
+// follow the retention set with SetRetention.
+const ADD_STATS_REFRESH_POLICY = `
+    DO $$
+    DECLARE
+        start INTERVAL;
+    BEGIN
+        SELECT (j.config->>'start_offset')::interval INTO start
+        FROM timescaledb_information.jobs j
+        JOIN timescaledb_information.continuous_aggregates c
+          ON c.materialization_hypertable_schema = j.hypertable_schema
+         AND c.materialization_hypertable_name = j.hypertable_name
+        WHERE c.view_name = 'trade_summary'
+          AND j.proc_name = 'policy_refresh_continuous_aggregate';
+
+        PERFORM add_continuous_aggregate_policy(
+            'trade_summary_stats',
+            start_offset => start,
+            end_offset => NULL,
+            schedule_interval => INTERVAL '1 hour',
+            if_not_exists => TRUE
+        );
+    END $$;
+`
+
+const REMOVE_STATS_REFRESH_POLICY = `
+    SELECT remove_continuous_aggregate_policy('trade_summary_stats', if_exists => TRUE);
+`
+
+// ADD_REFRESH_POLICY refreshes trade_summary every hour. Since files may be
+// loaded for any past day, the window has no start, and only days
+// invalidated by new trades or cancellations are materialized again.
+const ADD_REFRESH_POLICY = `
+    SELECT add_continuous_aggregate_policy(
+        'trade_summary',
+        start_offset => NULL,
+        end_offset => NULL,
+        schedule_interval => INTERVAL '1 hour',
+        if_not_exists => TRUE
+    );
+`
+
+const REMOVE_REFRESH_POLICY = `
+    SELECT remove_continuous_aggregate_policy('trade_summary', if_exists => TRUE);
 `
+
+// ADD_REFRESH_POLICY_SINCE is ADD_REFRESH_POLICY with a window starting $1
+// ago, so days whose trades were dropped by retention are never refreshed,
+// which would empty their summaries.
+const ADD_REFRESH_POLICY_SINCE = `
+    SELECT add_continuous_aggregate_policy(
+        'trade_summary',
+        start_offset => $1::interval,
+        end_offset => NULL,
+        schedule_interval => INTERVAL '1 hour',
+        if_not_exists => TRUE
+    );
+`
+
+// ENABLE_COMPRESSION groups compressed trades by ticker. The columns of
+// trade_key have to be either segments or ordering of compressed chunks. It
+// is skipped once enabled, since settings cannot change while there are
+// compressed chunks.
+const ENABLE_COMPRESSION = `
+    DO $$
+    BEGIN
+        IF NOT EXISTS (SELECT 1 FROM timescaledb_information.hypertables WHERE hypertable_name = 'trade' AND compression_enabled) THEN
+            ALTER TABLE trade SET (
+                timescaledb.compress,
+                timescaledb.compress_segmentby = 'ticker',
+                timescaledb.compress_orderby = 'entry_time DESC, trade_id'
+            );
+        END IF;
+    END $$;
+`
+
+const ADD_COMPRESSION_POLICY = `
+    SELECT add_compression_policy('trade', compress_after => $1::interval);
+`
+
+const REMOVE_COMPRESSION_POLICY = `
+    SELECT remove_compression_policy('trade', if_exists => TRUE);
+`
+
+const ADD_RETENTION_POLICY = `
+    SELECT add_retention_policy('trade', drop_after => $1::interval);
+`
+
+const REMOVE_RETENTION_POLICY = `
+    SELECT remove_retention_policy('trade', if_exists => TRUE);
+`
+
+// REFRESH_TRADE_SUMMARY materializes the continuous aggregate %s for the
+// days within $1 and $2. NULL bounds leave the window open.
+const REFRESH_TRADE_SUMMARY = `
+    CALL refresh_continuous_aggregate('%s', $1::timestamptz, $2::timestamptz);
+`
+
+// RETENTION_HORIZON returns when the oldest trades kept by the retention
+// policy of trade were entered, the start of the window of the refresh
+// policies added by SetRetention. There are no rows without retention.
+const RETENTION_HORIZON = `
+    SELECT now() - (config->>'drop_after')::interval
+    FROM timescaledb_information.jobs
+    WHERE proc_name = 'policy_retention'
+      AND hypertable_name = 'trade';
+`
+
 const CREATE_INDEXES = `
     CREATE INDEX IF NOT EXISTS idx_trade_summary_ticker_day ON trade_summary (ticker, date);
 `
 
 const DROP_TABLE = `
-    DROP TABLE trade;
+    DROP TABLE IF EXISTS trade;
+`
+
+const DROP_LOAD_MANIFEST = `
+    DROP TABLE IF EXISTS load_manifest;
 `
 
 const DROP_MATERIALIZED_VIEW = `
-    DROP MATERIALIZED VIEW trade_summary;
+    DROP MATERIALIZED VIEW IF EXISTS trade_summary;
+`
+
+const DROP_TRADE_SUMMARY_STATS = `
+    DROP MATERIALIZED VIEW IF EXISTS trade_summary_stats;
+`
+
+const CREATE_SCHEMA_MIGRATIONS = `
+    CREATE TABLE IF NOT EXISTS schema_migrations (
+        version INT PRIMARY KEY,
+        name TEXT NOT NULL,
+        applied_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+    );
+`
+
+const GET_SCHEMA_MIGRATIONS = `
+    SELECT version, applied_at FROM schema_migrations;
+`
+
+const INSERT_SCHEMA_MIGRATION = `
+    INSERT INTO schema_migrations (version, name) VALUES ($1, $2);
+`
+
+const DELETE_SCHEMA_MIGRATION = `
+    DELETE FROM schema_migrations WHERE version = $1;
+`
+
+const DROP_SCHEMA_MIGRATIONS = `
+    DROP TABLE IF EXISTS schema_migrations;
+`
+
+const TRADE_SUMMARY_EXISTS = `
+    SELECT to_regclass('trade_summary') IS NOT NULL
+      AND to_regclass('trade_summary_stats') IS NOT NULL;
 `
@@ -0,0 +1,712 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+// SQLite is a DB stored in a local SQLite file, for running the loader and
+// the API without TimescaleDB. Summaries are plain tables rebuilt by Refresh.
+type SQLite struct {
+	// db is the single writer connection, and reader the connections
+	// running the queries of the API, which WAL mode lets read while the
+	// writer writes, so a long tick stream does not block other queries.
+	db     *sql.DB
+	reader *sql.DB
+	path   string
+}
+
+func (s *SQLite) Close() {
+	s.reader.Close()
+	s.db.Close()
+}
+
+func (s *SQLite) Ready(ctx context.Context) error {
+	var exists bool
+
+	if err := s.reader.QueryRowContext(ctx, SQLITE_TRADE_SUMMARY_EXISTS).Scan(&exists); err != nil {
+		return err
+	}
+
+	if !exists {
+		return errors.New("trade_summary does not exist, run the migrations")
+	}
+
+	return nil
+}
+
+// sqliteMigrations is the schema, in order.
+var sqliteMigrations = []Migration{
+	{
+		Version: 1,
+		Name:    "create_trade",
+		Up:      []string{SQLITE_CREATE_TABLE},
+		Down:    []string{SQLITE_DROP_TABLE},
+	},
+	{
+		Version: 2,
+		Name:    "create_trade_key",
+		Up:      []string{SQLITE_CREATE_TRADE_KEY, SQLITE_CREATE_TRADE_INDEX},
+		Down:    []string{SQLITE_DROP_TRADE_INDEX, SQLITE_DROP_TRADE_KEY},
+	},
+	{
+		Version: 3,
+		Name:    "create_load_manifest",
+		Up:      []string{SQLITE_CREATE_LOAD_MANIFEST},
+		Down:    []string{SQLITE_DROP_LOAD_MANIFEST},
+	},
+	{
+		Version: 4,
+		Name:    "create_trade_summary",
+		Up:      []string{SQLITE_CREATE_SUMMARY_TABLE},
+		Down:    []string{SQLITE_DROP_SUMMARY_TABLE},
+	},
+	{
+		Version: 5,
+		Name:    "add_trade_summary_stats",
+		Up:      []string{SQLITE_ADD_SUMMARY_STATS},
+		Down:    []string{SQLITE_DROP_SUMMARY_STATS},
+	},
+	{
+		Version: 6,
+		Name:    "key_trade_by_session",
+		Up:      []string{SQLITE_DEDUPLICATE_TRADE_SESSIONS, SQLITE_DROP_TRADE_KEY, SQLITE_CREATE_TRADE_SESSION_KEY},
+		Down:    []string{SQLITE_DROP_TRADE_KEY, SQLITE_CREATE_TRADE_KEY},
+	},
+}
+
+func (s *SQLite) applied(ctx context.Context) (map[int]time.Time, error) {
+	if _, err := s.db.ExecContext(ctx, SQLITE_CREATE_SCHEMA_MIGRATIONS); err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.QueryContext(ctx, SQLITE_GET_SCHEMA_MIGRATIONS)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := map[int]time.Time{}
+
+	for rows.Next() {
+		var (
+			version   int
+			appliedAt int64
+		)
+
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, err
+		}
+
+		applied[version] = time.UnixMicro(appliedAt)
+	}
+
+	return applied, rows.Err()
+}
+
+func (s *SQLite) apply(ctx context.Context, m Migration, up bool) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	statements := m.Down
+	if up {
+		statements = m.Up
+	}
+
+	for _, q := range statements {
+		if _, err := tx.ExecContext(ctx, q); err != nil {
+			return err
+		}
+	}
+
+	if up {
+		_, err = tx.ExecContext(ctx, SQLITE_INSERT_SCHEMA_MIGRATION, m.Version, m.Name, time.Now().UnixMicro())
+	} else {
+		_, err = tx.ExecContext(ctx, SQLITE_DELETE_SCHEMA_MIGRATION, m.Version)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (s *SQLite) MigrateUp(ctx context.Context) (int, error) {
+	return migrateUp(ctx, s, sqliteMigrations)
+}
+
+func (s *SQLite) MigrateDown(ctx context.Context, steps int) (int, error) {
+	return migrateDown(ctx, s, sqliteMigrations, steps)
+}
+
+func (s *SQLite) MigrationStatus(ctx context.Context) ([]MigrationStatus, error) {
+	return migrationStatus(ctx, s, sqliteMigrations)
+}
+
+// CreateTable applies the pending migrations.
+func (s *SQLite) CreateTable(ctx context.Context) error {
+	_, err := s.MigrateUp(ctx)
+	return err
+}
+
+// PostLoad rebuilds the trade_summary table from the trades loaded so far.
+func (s *SQLite) PostLoad(ctx context.Context) error {
+	return s.Refresh(ctx, "", "")
+}
+
+func (s *SQLite) Refresh(ctx context.Context, from string, to string) error {
+	start, err := sqliteDate(from)
+	if err != nil {
+		return err
+	}
+
+	end, err := sqliteDate(to)
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, q := range []string{SQLITE_CLEAR_SUMMARY, SQLITE_BUILD_SUMMARY} {
+		if _, err := tx.ExecContext(ctx, q, start, end); err != nil {
+			return fmt.Errorf("could not refresh trade summary: %w", err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// sqliteTrade returns the values of SQLITE_CREATE_TRADE for a trade.
+func sqliteTrade(trade Trade, loc *time.Location) []any {
+	t := trade.EntryTime.In(loc)
+	_, offset := t.Zone()
+
+	return []any{
+		trade.Ticker,
+		trade.GrossAmount,
+		trade.Quantity,
+		t.UnixMicro(),
+		t.Format(time.DateOnly),
+		t.UnixMicro() + int64(offset)*int64(time.Second/time.Microsecond),
+		nullableDateOnly(trade.ReferenceDate),
+		trade.UpdateAction,
+		nullableInt(trade.TradeID),
+		trade.SessionType,
+		nullableInt(int64(trade.BuyerCode)),
+		nullableInt(int64(trade.SellerCode)),
+	}
+}
+
+// insert stores trades within a transaction, using a single prepared
+// statement.
+func (s *SQLite) insert(ctx context.Context, next func() (Trade, bool)) (int64, error) {
+	loc, err := loadLocation()
+	if err != nil {
+		return 0, err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx, SQLITE_CREATE_TRADE)
+	if err != nil {
+		return 0, err
+	}
+	defer stmt.Close()
+
+	var n int64
+
+	for trade, ok := next(); ok; trade, ok = next() {
+		if _, err := stmt.ExecContext(ctx, sqliteTrade(trade, loc)...); err != nil {
+			return 0, fmt.Errorf("could not create trades: %w", err)
+		}
+
+		n++
+	}
+
+	return n, tx.Commit()
+}
+
+func (s *SQLite) InsertMany(ctx context.Context, trades []Trade) error {
+	i := 0
+
+	_, err := s.insert(ctx, func() (Trade, bool) {
+		if i == len(trades) {
+			return Trade{}, false
+		}
+
+		i++
+
+		return trades[i-1], true
+	})
+
+	return err
+}
+
+func (s *SQLite) CopyMany(ctx context.Context, src TradeSource) (int64, error) {
+	var srcErr error
+
+	n, err := s.insert(ctx, func() (Trade, bool) {
+		if !src.Next() {
+			srcErr = src.Err()
+			return Trade{}, false
+		}
+
+		return src.Trade(), true
+	})
+
+	// the transaction is committed once src is drained, so a failing source
+	// has to be checked first
+	if srcErr != nil {
+		return 0, srcErr
+	}
+
+	return n, err
+}
+
+func (s *SQLite) CancelMany(ctx context.Context, trades []Trade) error {
+	loc, err := loadLocation()
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, trade := range trades {
+		date := trade.EntryTime.In(loc).Format(time.DateOnly)
+
+		if _, err := tx.ExecContext(ctx, SQLITE_CANCEL_TRADE, trade.Ticker, trade.TradeID, date); err != nil {
+			return fmt.Errorf("could not cancel trades: %w", err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (s *SQLite) GetManifest(ctx context.Context, fileName string) (Manifest, error) {
+	var (
+		manifest      Manifest
+		referenceDate sql.NullString
+		startedAt     int64
+		finishedAt    sql.NullInt64
+	)
+
+	err := s.reader.QueryRowContext(ctx, SQLITE_GET_MANIFEST, fileName).Scan(
+		&manifest.FileName,
+		&manifest.SHA256,
+		&manifest.RowCount,
+		&referenceDate,
+		&manifest.Status,
+		&startedAt,
+		&finishedAt,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return Manifest{FileName: fileName}, nil
+		}
+
+		return Manifest{}, err
+	}
+
+	if referenceDate.Valid {
+		if manifest.ReferenceDate, err = time.Parse(time.DateOnly, referenceDate.String); err != nil {
+			return Manifest{}, err
+		}
+	}
+
+	manifest.StartedAt = time.UnixMicro(startedAt)
+
+	if finishedAt.Valid {
+		manifest.FinishedAt = time.UnixMicro(finishedAt.Int64)
+	}
+
+	return manifest, nil
+}
+
+func (s *SQLite) SaveManifest(ctx context.Context, manifest Manifest) error {
+	var finishedAt any
+
+	if !manifest.FinishedAt.IsZero() {
+		finishedAt = manifest.FinishedAt.UnixMicro()
+	}
+
+	_, err := s.db.ExecContext(
+		ctx,
+		SQLITE_SAVE_MANIFEST,
+		manifest.FileName,
+		manifest.SHA256,
+		manifest.RowCount,
+		nullableDateOnly(manifest.ReferenceDate),
+		manifest.Status,
+		manifest.StartedAt.UnixMicro(),
+		finishedAt,
+	)
+
+	return err
+}
+
+// sqliteDate validates a date filter, returning nil for an empty one.
+func sqliteDate(date string) (any, error) {
+	if err := checkDates(date); err != nil {
+		return nil, err
+	}
+
+	return nullableDate(date), nil
+}
+
+func (s *SQLite) FetchTrades(ctx context.Context, q TradeQuery) (TradePage, error) {
+	if err := q.check(); err != nil {
+		return TradePage{}, err
+	}
+
+	after, err := q.cursorArgs()
+	if err != nil {
+		return TradePage{}, err
+	}
+
+	// a negative limit returns every row
+	limit := -1
+	if q.Limit > 0 {
+		limit = q.Limit + 1
+	}
+
+	rows, err := s.reader.QueryContext(ctx, q.orderBy(SQLITE_FETCH_TRADES_PAGE), nullableDate(q.From), nullableDate(q.To), q.pattern(), after[0], after[1], limit)
+	if err != nil {
+		return TradePage{}, err
+	}
+	defer rows.Close()
+
+	trades := []TradeSummary{}
+
+	for rows.Next() {
+		var trade TradeSummary
+
+		if err := rows.Scan(trade.columns()...); err != nil {
+			return TradePage{}, err
+		}
+
+		trades = append(trades, trade)
+	}
+
+	if err := rows.Err(); err != nil {
+		return TradePage{}, err
+	}
+
+	return q.page(trades)
+}
+
+func (s *SQLite) GetTrade(ctx context.Context, ticker string, from string, to string) (TradeSummary, error) {
+	start, err := sqliteDate(from)
+	if err != nil {
+		return TradeSummary{}, err
+	}
+
+	end, err := sqliteDate(to)
+	if err != nil {
+		return TradeSummary{}, err
+	}
+
+	var trade TradeSummary
+
+	err = s.reader.QueryRowContext(ctx, SQLITE_GET_TRADE, ticker, start, end).Scan(trade.columns()...)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return TradeSummary{}, fmt.Errorf("%w: no trades for %s", ErrNotFound, ticker)
+		}
+
+		return TradeSummary{}, err
+	}
+
+	return trade, nil
+}
+
+func (s *SQLite) GetTradeDays(ctx context.Context, ticker string, from string, to string) ([]TradeDay, error) {
+	start, err := sqliteDate(from)
+	if err != nil {
+		return nil, err
+	}
+
+	end, err := sqliteDate(to)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.reader.QueryContext(ctx, SQLITE_GET_TRADE_DAYS, ticker, start, end)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	days := []TradeDay{}
+
+	for rows.Next() {
+		var day TradeDay
+
+		if err := rows.Scan(day.columns()...); err != nil {
+			return nil, err
+		}
+
+		days = append(days, day)
+	}
+
+	return days, rows.Err()
+}
+
+func (s *SQLite) FetchTradeDays(ctx context.Context, tickers []string, from string, to string) (map[string][]TradeDay, error) {
+	if err := checkTickers(tickers); err != nil {
+		return nil, err
+	}
+
+	start, err := sqliteDate(from)
+	if err != nil {
+		return nil, err
+	}
+
+	end, err := sqliteDate(to)
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := json.Marshal(tickers)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.reader.QueryContext(ctx, SQLITE_FETCH_TRADE_DAYS, string(b), start, end)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	days := map[string][]TradeDay{}
+
+	for rows.Next() {
+		var (
+			ticker string
+			day    TradeDay
+		)
+
+		if err := rows.Scan(append([]any{&ticker}, day.columns()...)...); err != nil {
+			return nil, err
+		}
+
+		days[ticker] = append(days[ticker], day)
+	}
+
+	return days, rows.Err()
+}
+
+func (s *SQLite) FetchRanking(ctx context.Context, q RankingQuery) ([]Rank, error) {
+	if err := q.check(); err != nil {
+		return nil, err
+	}
+
+	query := fmt.Sprintf(SQLITE_FETCH_RANKING, rankingMetrics[q.Metric].sqlite, q.direction())
+
+	rows, err := s.reader.QueryContext(ctx, query, nullableDate(q.From), nullableDate(q.To), q.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ranks := []Rank{}
+
+	for rows.Next() {
+		var rank Rank
+
+		if err := rows.Scan(&rank.Ticker, &rank.Value); err != nil {
+			return nil, err
+		}
+
+		ranks = append(ranks, rank)
+	}
+
+	return ranks, rows.Err()
+}
+
+// wallClock returns the time at B3 whose wall clock, as unix microseconds,
+// is micros.
+func wallClock(micros int64, loc *time.Location) time.Time {
+	t := time.UnixMicro(micros).UTC()
+
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), loc)
+}
+
+func (s *SQLite) GetCandles(ctx context.Context, ticker string, interval string, from string, to string) ([]Candle, error) {
+	if err := checkInterval(interval); err != nil {
+		return nil, err
+	}
+
+	d := candleDurations[interval]
+
+	loc, err := loadLocation()
+	if err != nil {
+		return nil, err
+	}
+
+	start, err := sqliteDate(from)
+	if err != nil {
+		return nil, err
+	}
+
+	end, err := sqliteDate(to)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.reader.QueryContext(ctx, SQLITE_GET_CANDLES, ticker, d.Microseconds(), start, end)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	candles := []Candle{}
+
+	for rows.Next() {
+		var (
+			candle Candle
+			bucket int64
+		)
+
+		err := rows.Scan(
+			&bucket,
+			&candle.Open,
+			&candle.High,
+			&candle.Low,
+			&candle.Close,
+			&candle.Volume,
+			&candle.TradeCount,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		candle.Time = wallClock(bucket, loc)
+		candles = append(candles, candle)
+	}
+
+	return candles, rows.Err()
+}
+
+// sqliteTickRows reads ticks from database/sql rows. Since the database has
+// a single connection, other queries wait for the rows to be closed.
+type sqliteTickRows struct {
+	rows *sql.Rows
+	loc  *time.Location
+	tick Tick
+	err  error
+}
+
+func (r *sqliteTickRows) Next() bool {
+	if r.err != nil || !r.rows.Next() {
+		return false
+	}
+
+	var micros int64
+
+	r.err = r.rows.Scan(&micros, &r.tick.Price, &r.tick.Quantity, &r.tick.TradeID)
+	r.tick.Time = time.UnixMicro(micros).In(r.loc)
+
+	return r.err == nil
+}
+
+func (r *sqliteTickRows) Tick() Tick { return r.tick }
+
+func (r *sqliteTickRows) Err() error {
+	if r.err != nil {
+		return r.err
+	}
+
+	return r.rows.Err()
+}
+
+func (r *sqliteTickRows) Close() { r.rows.Close() }
+
+func (s *SQLite) GetTicks(ctx context.Context, q TickQuery) (TickRows, error) {
+	start, end, err := q.bounds()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.reader.QueryContext(ctx, SQLITE_GET_TICKS, q.Ticker, start.UnixMicro(), end.UnixMicro())
+	if err != nil {
+		return nil, err
+	}
+
+	return &sqliteTickRows{rows: rows, loc: start.Location()}, nil
+}
+
+// nullableDateOnly formats a date as YYYY-MM-DD, turning a zero time into a
+// SQL NULL.
+func nullableDateOnly(t time.Time) any {
+	if t.IsZero() {
+		return nil
+	}
+
+	return t.Format(time.DateOnly)
+}
+
+// sqliteDSN returns the URI of the SQLite database at path. The path is
+// escaped, since SQLite decodes it, so characters such as ?, # and % are
+// not taken as the start of the parameters or as escapes.
+func sqliteDSN(path string, pragmas ...string) string {
+	params := url.Values{"_pragma": append([]string{"busy_timeout(5000)", "journal_mode(WAL)"}, pragmas...)}
+
+	return (&url.URL{Scheme: "file", Opaque: url.PathEscape(path), RawQuery: params.Encode()}).String()
+}
+
+// sqliteReaders is how many queries of the API run at once.
+const sqliteReaders = 4
+
+// NewSQLite opens, creating it if needed, the SQLite database at path.
+func NewSQLite(ctx context.Context, path string) (SQLite, error) {
+	conn, err := sql.Open("sqlite", sqliteDSN(path))
+	if err != nil {
+		return SQLite{}, fmt.Errorf("could not open sqlite database: %w", err)
+	}
+
+	// SQLite allows a single writer, so concurrent inserts share a connection
+	conn.SetMaxOpenConns(1)
+
+	// the writer creates the file and turns WAL mode on before any reader
+	if err := conn.PingContext(ctx); err != nil {
+		conn.Close()
+		return SQLite{}, fmt.Errorf("could not open sqlite database %s: %w", path, err)
+	}
+
+	reader, err := sql.Open("sqlite", sqliteDSN(path, "query_only(1)"))
+	if err != nil {
+		conn.Close()
+		return SQLite{}, fmt.Errorf("could not open sqlite database: %w", err)
+	}
+
+	reader.SetMaxOpenConns(sqliteReaders)
+
+	return SQLite{
+		db:     conn,
+		reader: reader,
+		path:   path,
+	}, nil
+}
@@ -0,0 +1,432 @@
+package db
+
+// Times are stored by SQLite as unix microseconds and dates as YYYY-MM-DD
+// text. Since SQLite has no time zones, each trade also keeps its session date
+// and wall clock time at B3, which summaries and candles are bucketed by.
+
+const SQLITE_CREATE_TABLE = `
+    CREATE TABLE IF NOT EXISTS trade (
+        ticker TEXT NOT NULL,
+        gross_amount REAL,
+        quantity INTEGER NOT NULL,
+        entry_time INTEGER NOT NULL,
+        session_date TEXT NOT NULL,
+        local_time INTEGER NOT NULL,
+        reference_date TEXT,
+        update_action INTEGER,
+        trade_id INTEGER,
+        session_type INTEGER,
+        buyer_code INTEGER,
+        seller_code INTEGER,
+        cancelled INTEGER NOT NULL DEFAULT 0
+    );
+`
+
+const SQLITE_CREATE_TRADE_KEY = `
+    CREATE UNIQUE INDEX IF NOT EXISTS trade_key ON trade (ticker, trade_id, entry_time);
+`
+
+// SQLITE_CREATE_TRADE_SESSION_KEY replaces trade_key with the natural key of
+// a trade, since B3 trade IDs are unique per ticker and session: a trade
+// corrected to another time replaces the original.
+const SQLITE_CREATE_TRADE_SESSION_KEY = `
+    CREATE UNIQUE INDEX IF NOT EXISTS trade_key ON trade (ticker, trade_id, session_date);
+`
+
+// SQLITE_DEDUPLICATE_TRADE_SESSIONS is DEDUPLICATE_TRADE_SESSIONS, run before
+// SQLITE_CREATE_TRADE_SESSION_KEY.
+const SQLITE_DEDUPLICATE_TRADE_SESSIONS = `
+    DELETE FROM trade
+    WHERE rowid IN (
+        SELECT rowid
+        FROM (
+            SELECT
+                rowid,
+                ROW_NUMBER() OVER (
+                    PARTITION BY ticker, trade_id, session_date
+                    ORDER BY entry_time DESC
+                ) AS n
+            FROM
+                trade
+            WHERE
+                trade_id IS NOT NULL
+        )
+        WHERE n > 1
+    );
+`
+
+const SQLITE_CREATE_TRADE_INDEX = `
+    CREATE INDEX IF NOT EXISTS idx_trade_ticker_session_date ON trade (ticker, session_date);
+`
+
+const SQLITE_CREATE_LOAD_MANIFEST = `
+    CREATE TABLE IF NOT EXISTS load_manifest (
+        file_name TEXT PRIMARY KEY,
+        sha256 TEXT NOT NULL,
+        row_count INTEGER NOT NULL DEFAULT 0,
+        reference_date TEXT,
+        status TEXT NOT NULL,
+        started_at INTEGER NOT NULL,
+        finished_at INTEGER
+    );
+`
+
+const SQLITE_CREATE_SUMMARY_TABLE = `
+    CREATE TABLE IF NOT EXISTS trade_summary (
+        date TEXT NOT NULL,
+        ticker TEXT NOT NULL,
+        max_range_value REAL,
+        total_quantity INTEGER,
+        PRIMARY KEY (ticker, date)
+    );
+`
+
+// SQLITE_ADD_SUMMARY_STATS adds the columns of CREATE_TRADE_SUMMARY_STATS to
+// trade_summary. They are filled in by the next refresh.
+const SQLITE_ADD_SUMMARY_STATS = `
+    ALTER TABLE trade_summary ADD COLUMN open_price REAL;
+    ALTER TABLE trade_summary ADD COLUMN close_price REAL;
+    ALTER TABLE trade_summary ADD COLUMN min_range_value REAL;
+    ALTER TABLE trade_summary ADD COLUMN financial_volume REAL;
+    ALTER TABLE trade_summary ADD COLUMN trade_count INTEGER;
+`
+
+const SQLITE_DROP_SUMMARY_STATS = `
+    ALTER TABLE trade_summary DROP COLUMN open_price;
+    ALTER TABLE trade_summary DROP COLUMN close_price;
+    ALTER TABLE trade_summary DROP COLUMN min_range_value;
+    ALTER TABLE trade_summary DROP COLUMN financial_volume;
+    ALTER TABLE trade_summary DROP COLUMN trade_count;
+`
+
+// SQLITE_CREATE_TRADE upserts a trade by trade_key, clearing cancelled as
+// CREATE_TRADE does.
+const SQLITE_CREATE_TRADE = `
+    INSERT INTO trade (
+        ticker, gross_amount, quantity, entry_time, session_date, local_time,
+        reference_date, update_action, trade_id, session_type, buyer_code, seller_code
+    )
+    VALUES (?, ROUND(?, 3), ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+    ON CONFLICT (ticker, trade_id, session_date) DO UPDATE SET
+        gross_amount = excluded.gross_amount,
+        quantity = excluded.quantity,
+        entry_time = excluded.entry_time,
+        local_time = excluded.local_time,
+        reference_date = excluded.reference_date,
+        update_action = excluded.update_action,
+        session_type = excluded.session_type,
+        buyer_code = excluded.buyer_code,
+        seller_code = excluded.seller_code,
+        cancelled = 0
+`
+
+const SQLITE_CANCEL_TRADE = `
+    UPDATE trade
+    SET cancelled = 1
+    WHERE ticker = ? AND trade_id = ? AND session_date = ?
+`
+
+const SQLITE_GET_MANIFEST = `
+    SELECT
+      file_name,
+      sha256,
+      row_count,
+      reference_date,
+      status,
+      started_at,
+      finished_at
+    FROM
+      load_manifest
+    WHERE file_name = ?;
+`
+
+const SQLITE_SAVE_MANIFEST = `
+    INSERT INTO load_manifest (file_name, sha256, row_count, reference_date, status, started_at, finished_at)
+    VALUES (?, ?, ?, ?, ?, ?, ?)
+    ON CONFLICT (file_name) DO UPDATE SET
+        sha256 = excluded.sha256,
+        row_count = excluded.row_count,
+        reference_date = excluded.reference_date,
+        status = excluded.status,
+        started_at = excluded.started_at,
+        finished_at = excluded.finished_at
+`
+
+const SQLITE_CLEAR_SUMMARY = `
+    DELETE FROM trade_summary
+    WHERE (?1 IS NULL OR date >= ?1) AND (?2 IS NULL OR date <= ?2);
+`
+
+// SQLITE_BUILD_SUMMARY takes the first and last prices of each day with
+// window functions, as SQLITE_GET_CANDLES does.
+const SQLITE_BUILD_SUMMARY = `
+    INSERT INTO trade_summary (
+        date, ticker, max_range_value, total_quantity,
+        open_price, close_price, min_range_value, financial_volume, trade_count
+    )
+    SELECT
+        session_date,
+        ticker,
+        MAX(gross_amount),
+        SUM(quantity),
+        MAX(CASE WHEN first_row = 1 THEN gross_amount END),
+        MAX(CASE WHEN last_row = 1 THEN gross_amount END),
+        MIN(gross_amount),
+        SUM(gross_amount * quantity),
+        COUNT(*)
+    FROM (
+        SELECT
+            session_date,
+            ticker,
+            gross_amount,
+            quantity,
+            ROW_NUMBER() OVER (PARTITION BY session_date, ticker ORDER BY entry_time) AS first_row,
+            ROW_NUMBER() OVER (PARTITION BY session_date, ticker ORDER BY entry_time DESC) AS last_row
+        FROM
+            trade
+        WHERE
+            NOT cancelled
+            AND (?1 IS NULL OR session_date >= ?1)
+            AND (?2 IS NULL OR session_date <= ?2)
+    )
+    GROUP BY
+        session_date, ticker;
+`
+
+// SQLITE_SUMMARY_STATS is SUMMARY_STATS. Since SQLite has no first and last
+// aggregates, the days are selected with the prices of the first and last
+// days of their ticker, as first_open and last_close.
+const SQLITE_SUMMARY_STATS = `
+        MAX(first_open) AS open_price,
+        MAX(last_close) AS close_price,
+        MIN(min_range_value) AS min_range_value,
+        SUM(financial_volume) / NULLIF(SUM(total_quantity), 0) AS vwap,
+        SUM(total_quantity) AS volume,
+        SUM(financial_volume) AS financial_volume,
+        SUM(trade_count) AS trade_count,
+        CAST(SUM(total_quantity) AS REAL) / NULLIF(SUM(trade_count), 0) AS avg_trade_size`
+
+// SQLITE_SUMMARY_DAYS are the window functions of SQLITE_SUMMARY_STATS.
+const SQLITE_SUMMARY_DAYS = `
+        *,
+        FIRST_VALUE(open_price) OVER days AS first_open,
+        LAST_VALUE(close_price) OVER days AS last_close`
+
+// SQLITE_SUMMARY_WINDOW is the window of SQLITE_SUMMARY_DAYS.
+const SQLITE_SUMMARY_WINDOW = `
+      WINDOW days AS (PARTITION BY ticker ORDER BY date ROWS BETWEEN UNBOUNDED PRECEDING AND UNBOUNDED FOLLOWING)`
+
+// SQLITE_FETCH_TRADES_PAGE is FETCH_TRADES_PAGE, whose fourth verb is left
+// out since SQLite compares values of any type.
+const SQLITE_FETCH_TRADES_PAGE = `
+    SELECT
+      ticker,
+      max_range_value,
+      max_daily_volume,
+      open_price,
+      close_price,
+      min_range_value,
+      vwap,
+      volume,
+      financial_volume,
+      trade_count,
+      avg_trade_size
+    FROM (
+      SELECT
+        ticker,
+        MAX(max_range_value) AS max_range_value,
+        MAX(total_quantity) AS max_daily_volume,
+        ` + SQLITE_SUMMARY_STATS + `
+      FROM (
+        SELECT ` + SQLITE_SUMMARY_DAYS + `
+        FROM
+          trade_summary
+        WHERE (?1 IS NULL OR date >= ?1) AND (?2 IS NULL OR date <= ?2)
+          AND (?3 IS NULL OR ticker LIKE ?3)
+        ` + SQLITE_SUMMARY_WINDOW + `
+      )
+      GROUP BY
+        ticker
+    )
+    WHERE ?5 IS NULL OR (%[1]s, ticker) %[3]s (?4, ?5)
+    ORDER BY
+      %[1]s %[2]s, ticker %[2]s
+    LIMIT ?6;
+`
+
+const SQLITE_FETCH_RANKING = `
+    SELECT
+      ticker,
+      value
+    FROM (
+      SELECT
+        ticker,
+        CAST(%[1]s AS REAL) AS value
+      FROM (
+        SELECT ` + SQLITE_SUMMARY_DAYS + `
+        FROM
+          trade_summary
+        WHERE (?1 IS NULL OR date >= ?1) AND (?2 IS NULL OR date <= ?2)
+        ` + SQLITE_SUMMARY_WINDOW + `
+      )
+      GROUP BY
+        ticker
+    )
+    WHERE value IS NOT NULL
+    ORDER BY
+      value %[2]s, ticker
+    LIMIT ?3;
+`
+
+const SQLITE_GET_TRADE = `
+    SELECT
+      ticker,
+      MAX(max_range_value) AS max_range_value,
+      MAX(total_quantity) AS max_daily_volume,
+      ` + SQLITE_SUMMARY_STATS + `
+    FROM (
+      SELECT ` + SQLITE_SUMMARY_DAYS + `
+      FROM
+        trade_summary
+      WHERE ticker = ?1 AND (?2 IS NULL OR date >= ?2) AND (?3 IS NULL OR date <= ?3)
+      ` + SQLITE_SUMMARY_WINDOW + `
+    )
+    GROUP BY
+      ticker;
+`
+
+const SQLITE_GET_TRADE_DAYS = `
+    SELECT
+      date,
+      max_range_value,
+      total_quantity,
+      open_price,
+      close_price,
+      min_range_value,
+      financial_volume / NULLIF(total_quantity, 0) AS vwap,
+      financial_volume,
+      trade_count,
+      CAST(total_quantity AS REAL) / NULLIF(trade_count, 0) AS avg_trade_size
+    FROM
+      trade_summary
+    WHERE ticker = ?1 AND (?2 IS NULL OR date >= ?2) AND (?3 IS NULL OR date <= ?3)
+    ORDER BY
+      date;
+`
+
+// SQLITE_FETCH_TRADE_DAYS is SQLITE_GET_TRADE_DAYS for the tickers in the
+// JSON array ?1, since SQLite has no arrays.
+const SQLITE_FETCH_TRADE_DAYS = `
+    SELECT
+      ticker,
+      date,
+      max_range_value,
+      total_quantity,
+      open_price,
+      close_price,
+      min_range_value,
+      financial_volume / NULLIF(total_quantity, 0) AS vwap,
+      financial_volume,
+      trade_count,
+      CAST(total_quantity AS REAL) / NULLIF(trade_count, 0) AS avg_trade_size
+    FROM
+      trade_summary
+    WHERE ticker IN (SELECT value FROM json_each(?1))
+      AND (?2 IS NULL OR date >= ?2) AND (?3 IS NULL OR date <= ?3)
+    ORDER BY
+      ticker, date;
+`
+
+// SQLITE_GET_CANDLES buckets trades by their wall clock time at B3, as
+// time_bucket does with a time zone, taking the first and last prices of each
+// bucket with window functions.
+const SQLITE_GET_CANDLES = `
+    WITH t AS (
+      SELECT
+        local_time - local_time % ?2 AS bucket,
+        gross_amount,
+        quantity,
+        ROW_NUMBER() OVER (PARTITION BY local_time - local_time % ?2 ORDER BY entry_time) AS first_row,
+        ROW_NUMBER() OVER (PARTITION BY local_time - local_time % ?2 ORDER BY entry_time DESC) AS last_row
+      FROM
+        trade
+      WHERE ticker = ?1
+        AND NOT cancelled
+        AND (?3 IS NULL OR session_date >= ?3)
+        AND (?4 IS NULL OR session_date <= ?4)
+    )
+    SELECT
+      bucket,
+      MAX(CASE WHEN first_row = 1 THEN gross_amount END) AS open,
+      MAX(gross_amount) AS high,
+      MIN(gross_amount) AS low,
+      MAX(CASE WHEN last_row = 1 THEN gross_amount END) AS close,
+      SUM(quantity) AS volume,
+      COUNT(*) AS trade_count
+    FROM
+      t
+    GROUP BY
+      bucket
+    ORDER BY
+      bucket;
+`
+
+const SQLITE_GET_TICKS = `
+    SELECT
+      entry_time,
+      gross_amount,
+      quantity,
+      COALESCE(trade_id, 0)
+    FROM
+      trade
+    WHERE ticker = ?1
+      AND NOT cancelled
+      AND entry_time >= ?2
+      AND entry_time < ?3
+    ORDER BY
+      entry_time, trade_id;
+`
+
+const SQLITE_DROP_TRADE_KEY = `
+    DROP INDEX IF EXISTS trade_key;
+`
+
+const SQLITE_DROP_TRADE_INDEX = `
+    DROP INDEX IF EXISTS idx_trade_ticker_session_date;
+`
+
+const SQLITE_DROP_TABLE = `
+    DROP TABLE IF EXISTS trade;
+`
+
+const SQLITE_DROP_LOAD_MANIFEST = `
+    DROP TABLE IF EXISTS load_manifest;
+`
+
+const SQLITE_DROP_SUMMARY_TABLE = `
+    DROP TABLE IF EXISTS trade_summary;
+`
+
+const SQLITE_CREATE_SCHEMA_MIGRATIONS = `
+    CREATE TABLE IF NOT EXISTS schema_migrations (
+        version INTEGER PRIMARY KEY,
+        name TEXT NOT NULL,
+        applied_at INTEGER NOT NULL
+    );
+`
+
+const SQLITE_GET_SCHEMA_MIGRATIONS = `
+    SELECT version, applied_at FROM schema_migrations;
+`
+
+const SQLITE_INSERT_SCHEMA_MIGRATION = `
+    INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?);
+`
+
+const SQLITE_DELETE_SCHEMA_MIGRATION = `
+    DELETE FROM schema_migrations WHERE version = ?;
+`
+
+const SQLITE_TRADE_SUMMARY_EXISTS = `
+    SELECT EXISTS (SELECT 1 FROM sqlite_master WHERE type = 'table' AND name = 'trade_summary');
+`
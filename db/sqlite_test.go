@@ -0,0 +1,114 @@
+package db
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSQLite(t *testing.T) {
+	testDB(t, func(t *testing.T) DB {
+		s, err := NewSQLite(context.Background(), filepath.Join(t.TempDir(), "b3.db"))
+		if err != nil {
+			t.Fatalf("expected no error opening sqlite, got %s", err)
+		}
+
+		t.Cleanup(s.Close)
+
+		if err := s.CreateTable(context.Background()); err != nil {
+			t.Fatalf("expected no error creating table in sqlite, got %s", err)
+		}
+
+		return &s
+	})
+}
+
+func TestSQLiteMigrations(t *testing.T) {
+	s, err := NewSQLite(context.Background(), filepath.Join(t.TempDir(), "b3.db"))
+	if err != nil {
+		t.Fatalf("expected no error opening sqlite, got %s", err)
+	}
+	defer s.Close()
+
+	if err := s.CreateTable(context.Background()); err != nil {
+		t.Fatalf("expected no error creating table in sqlite, got %s", err)
+	}
+
+	testMigrations(t, &s)
+}
+
+func TestSQLitePath(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "b3 ?a=b#c%41.db")
+
+	s, err := NewSQLite(context.Background(), path)
+	if err != nil {
+		t.Fatalf("expected no error opening sqlite, got %s", err)
+	}
+	defer s.Close()
+
+	var mode string
+
+	err = s.db.QueryRowContext(context.Background(), "PRAGMA journal_mode").Scan(&mode)
+	assert.NoError(t, err, "expected no error reading the journal mode, got %s", err)
+	assert.Equal(t, mode, "wal", "expected the pragmas to be applied, got journal mode %s", mode)
+
+	_, err = os.Stat(path)
+	assert.NoError(t, err, "expected the database to be created at %s, got %s", path, err)
+}
+
+func TestSQLiteReadsWhileStreaming(t *testing.T) {
+	s, err := NewSQLite(context.Background(), filepath.Join(t.TempDir(), "b3.db"))
+	if err != nil {
+		t.Fatalf("expected no error opening sqlite, got %s", err)
+	}
+	defer s.Close()
+
+	if err := s.CreateTable(context.Background()); err != nil {
+		t.Fatalf("expected no error creating table in sqlite, got %s", err)
+	}
+
+	trades := []Trade{
+		{Ticker: TICKER, GrossAmount: 1, Quantity: 10, EntryTime: day(t, 0, 10, 0), TradeID: 1},
+		{Ticker: TICKER, GrossAmount: 2, Quantity: 20, EntryTime: day(t, 0, 11, 0), TradeID: 2},
+	}
+
+	err = s.InsertMany(context.Background(), trades)
+	assert.NoError(t, err, "expected no error inserting trades, got %s", err)
+
+	// a tick stream being read holds its connection
+	rows, err := s.GetTicks(context.Background(), TickQuery{Ticker: TICKER, Date: "2024-07-01"})
+	assert.NoError(t, err, "expected no error getting ticks, got %s", err)
+	defer rows.Close()
+
+	assert.True(t, rows.Next(), "expected a tick, got %v", rows.Err())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	err = s.Ready(ctx)
+	assert.NoError(t, err, "expected readiness not to wait for the stream, got %s", err)
+
+	err = s.InsertMany(ctx, trades)
+	assert.NoError(t, err, "expected no error writing while streaming, got %s", err)
+
+	err = s.PostLoad(ctx)
+	assert.NoError(t, err, "expected no error refreshing while streaming, got %s", err)
+
+	_, err = s.GetTrade(ctx, TICKER, "", "")
+	assert.NoError(t, err, "expected no error reading while streaming, got %s", err)
+}
+
+func TestSQLiteReadyWithoutMigrations(t *testing.T) {
+	s, err := NewSQLite(context.Background(), filepath.Join(t.TempDir(), "b3.db"))
+	if err != nil {
+		t.Fatalf("expected no error opening sqlite, got %s", err)
+	}
+	defer s.Close()
+
+	err = s.Ready(context.Background())
+	assert.Error(t, err, "expected a database without trade_summary not to be ready")
+}
@@ -0,0 +1,97 @@
+package db
+
+import (
+	"fmt"
+	"time"
+)
+
+// Tick is a single trade, as stored in the trade table.
+type Tick struct {
+	Time     time.Time `json:"time"`
+	Price    float64   `json:"price"`
+	Quantity int64     `json:"quantity"`
+	TradeID  int64     `json:"trade_id,omitempty"`
+}
+
+// TickQuery selects the trades of a ticker in a B3 session.
+type TickQuery struct {
+	Ticker string
+	// Date is the session, as YYYY-MM-DD. It is required, since a ticker
+	// may have millions of trades.
+	Date string
+	// FromTime and ToTime limit the trades to a window of the wall clock
+	// time at B3, as HH:MM or HH:MM:SS, from inclusive and to exclusive.
+	// Empty times leave the window open.
+	FromTime string
+	ToTime   string
+}
+
+// TickRows iterates over the trades selected by GetTicks in time order,
+// reading them from the database as they are needed: Next advances to the
+// next trade, Tick returns it and Err reports what stopped the iteration, if
+// anything. Close releases the connection and has to be called once done.
+type TickRows interface {
+	Next() bool
+	Tick() Tick
+	Err() error
+	Close()
+}
+
+// parseClock parses a wall clock time of a TickQuery as a duration since
+// midnight.
+func parseClock(name string, clock string) (time.Duration, error) {
+	for _, layout := range []string{"15:04", time.TimeOnly} {
+		t, err := time.Parse(layout, clock)
+		if err == nil {
+			return t.Sub(time.Date(0, 1, 1, 0, 0, 0, 0, time.UTC)), nil
+		}
+	}
+
+	return 0, fmt.Errorf("%w: %s %q, expected HH:MM or HH:MM:SS", ErrInvalidArgument, name, clock)
+}
+
+// bounds validates the query, returning the window of entry times it
+// selects, from inclusive and to exclusive.
+func (q TickQuery) bounds() (time.Time, time.Time, error) {
+	if q.Date == "" {
+		return time.Time{}, time.Time{}, fmt.Errorf("%w: date is required", ErrInvalidArgument)
+	}
+
+	loc, err := loadLocation()
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+
+	session, err := parseDate(q.Date, loc)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+
+	start, end := session, session.AddDate(0, 0, 1)
+
+	if q.FromTime != "" {
+		d, err := parseClock("from_time", q.FromTime)
+		if err != nil {
+			return time.Time{}, time.Time{}, err
+		}
+
+		// adding to the wall clock, rather than to the instant, keeps the
+		// time right on days when daylight saving time changes
+		start = time.Date(session.Year(), session.Month(), session.Day(), 0, 0, 0, int(d), loc)
+	}
+
+	if q.ToTime != "" {
+		d, err := parseClock("to_time", q.ToTime)
+		if err != nil {
+			return time.Time{}, time.Time{}, err
+		}
+
+		end = time.Date(session.Year(), session.Month(), session.Day(), 0, 0, 0, int(d), loc)
+	}
+
+	if end.Before(start) {
+		return time.Time{}, time.Time{}, fmt.Errorf("%w: from_time %s is after to_time %s", ErrInvalidArgument, q.FromTime, q.ToTime)
+	}
+
+	return start, end, nil
+}
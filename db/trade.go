@@ -2,10 +2,32 @@ package db
 
 import "time"
 
+// Update actions reported by B3 in the AcaoAtualizacao column. Corrections
+// are stored as trades, replacing the trade with the same ID in the session,
+// as any trade loaded again does.
+const (
+	UpdateActionNew     = 0
+	UpdateActionCorrect = 1
+	UpdateActionCancel  = 2
+)
+
 type Trade struct {
-	Ticker      string
-	GrossAmount float64
-	Quantity    int64
-	EntryTime   time.Time
-	Date        time.Time
+	Ticker        string
+	GrossAmount   float64
+	Quantity      int64
+	EntryTime     time.Time
+	ReferenceDate time.Time
+	UpdateAction  int
+	// TradeID is the B3 trade identifier, unique per ticker and session. Trades
+	// without one (zero) are never deduplicated.
+	TradeID     int64
+	SessionType int
+	// BuyerCode and SellerCode are the B3 participant codes of the brokers on
+	// each side of the trade, zero when B3 does not disclose them.
+	BuyerCode  int
+	SellerCode int
 }
+
+// IsCancellation reports whether the row cancels a previously reported trade
+// instead of being a trade itself.
+func (t Trade) IsCancellation() bool { return t.UpdateAction == UpdateActionCancel }
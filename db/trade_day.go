@@ -0,0 +1,36 @@
+package db
+
+// TradeDay is a day of trades of a ticker, as summarized in trade_summary.
+// As in TradeSummary, the statistics after Volume are nil for days summarized
+// before they existed and not refreshed since.
+type TradeDay struct {
+	// Date is the B3 session, as YYYY-MM-DD.
+	Date          string  `json:"date"`
+	MaxRangeValue float64 `json:"max_range_value"`
+	Volume        int64   `json:"volume"`
+
+	Open            *float64 `json:"open,omitempty"`
+	Close           *float64 `json:"close,omitempty"`
+	MinRangeValue   *float64 `json:"min_range_value,omitempty"`
+	VWAP            *float64 `json:"vwap,omitempty"`
+	FinancialVolume *float64 `json:"financial_volume,omitempty"`
+	TradeCount      *int64   `json:"trade_count,omitempty"`
+	AvgTradeSize    *float64 `json:"avg_trade_size,omitempty"`
+}
+
+// columns returns the destinations of the columns selected for a day by
+// GET_TRADE_DAYS and SQLITE_GET_TRADE_DAYS, in order.
+func (d *TradeDay) columns() []any {
+	return []any{
+		&d.Date,
+		&d.MaxRangeValue,
+		&d.Volume,
+		&d.Open,
+		&d.Close,
+		&d.MinRangeValue,
+		&d.VWAP,
+		&d.FinancialVolume,
+		&d.TradeCount,
+		&d.AvgTradeSize,
+	}
+}
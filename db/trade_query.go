@@ -0,0 +1,189 @@
+package db
+
+import (
+	"cmp"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Fields trade summaries can be sorted by.
+const (
+	SortTicker         = "ticker"
+	SortMaxRangeValue  = "max_range_value"
+	SortMaxDailyVolume = "max_daily_volume"
+)
+
+// sortTypes are the SQL types of the fields trade summaries can be sorted by.
+var sortTypes = map[string]string{
+	SortTicker:         "text",
+	SortMaxRangeValue:  "numeric",
+	SortMaxDailyVolume: "bigint",
+}
+
+// TradeQuery selects a page of the summaries returned by FetchTrades.
+type TradeQuery struct {
+	// From and To are the first and last days summarized, inclusive. Empty
+	// dates leave the range open.
+	From string
+	To   string
+	// Ticker keeps only the matching tickers, where * matches any
+	// characters, as in PETR*. Tickers are matched in upper case.
+	Ticker string
+	// Sort is the field summaries are ordered by, ticker by default. Ties
+	// are ordered by ticker.
+	Sort string
+	Desc bool
+	// Limit is the maximum number of summaries in a page, or every summary
+	// when zero.
+	Limit int
+	// Cursor is the NextCursor of the previous page, empty for the first.
+	Cursor string
+}
+
+// TradePage is a page of trade summaries. NextCursor is empty on the last
+// page.
+type TradePage struct {
+	Trades     []TradeSummary
+	NextCursor string
+}
+
+// cursor is the last summary of a page, along with the order it was in.
+type cursor struct {
+	Sort  string       `json:"s"`
+	Desc  bool         `json:"d"`
+	Trade TradeSummary `json:"t"`
+}
+
+// check validates the query, filling in the default sort.
+func (q *TradeQuery) check() error {
+	if q.Sort == "" {
+		q.Sort = SortTicker
+	}
+
+	if _, ok := sortTypes[q.Sort]; !ok {
+		return fmt.Errorf("%w: sort field %q", ErrInvalidArgument, q.Sort)
+	}
+
+	if q.Limit < 0 {
+		return fmt.Errorf("%w: limit %d", ErrInvalidArgument, q.Limit)
+	}
+
+	if strings.ContainsAny(q.Ticker, `%_\`) {
+		return fmt.Errorf("%w: ticker pattern %q", ErrInvalidArgument, q.Ticker)
+	}
+
+	return checkDates(q.From, q.To)
+}
+
+// pattern returns Ticker as a LIKE pattern, or nil to match every ticker.
+func (q TradeQuery) pattern() any {
+	if q.Ticker == "" {
+		return nil
+	}
+
+	return strings.ReplaceAll(strings.ToUpper(q.Ticker), "*", "%")
+}
+
+// after decodes Cursor, returning nil for the first page.
+func (q TradeQuery) after() (*TradeSummary, error) {
+	if q.Cursor == "" {
+		return nil, nil
+	}
+
+	b, err := base64.RawURLEncoding.DecodeString(q.Cursor)
+	if err != nil {
+		return nil, fmt.Errorf("%w: cursor %q", ErrInvalidArgument, q.Cursor)
+	}
+
+	var c cursor
+
+	if err := json.Unmarshal(b, &c); err != nil {
+		return nil, fmt.Errorf("%w: cursor %q", ErrInvalidArgument, q.Cursor)
+	}
+
+	if c.Sort != q.Sort || c.Desc != q.Desc {
+		return nil, fmt.Errorf("%w: cursor of a page sorted by another field", ErrInvalidArgument)
+	}
+
+	return &c.Trade, nil
+}
+
+// cursorArgs returns the sort value and ticker of the summary a page starts
+// after, as SQL arguments, or NULLs for the first page.
+func (q TradeQuery) cursorArgs() ([]any, error) {
+	last, err := q.after()
+	if err != nil {
+		return nil, err
+	}
+
+	if last == nil {
+		return []any{nil, nil}, nil
+	}
+
+	switch q.Sort {
+	case SortMaxRangeValue:
+		return []any{last.MaxRangeValue, last.Ticker}, nil
+	case SortMaxDailyVolume:
+		return []any{last.MaxDailyVolume, last.Ticker}, nil
+	default:
+		return []any{last.Ticker, last.Ticker}, nil
+	}
+}
+
+// page builds a page out of up to Limit + 1 summaries, the extra one only
+// telling there is a next page.
+func (q TradeQuery) page(trades []TradeSummary) (TradePage, error) {
+	if q.Limit == 0 || len(trades) <= q.Limit {
+		return TradePage{Trades: trades}, nil
+	}
+
+	trades = trades[:q.Limit]
+	last := trades[len(trades)-1]
+
+	// only the fields pages are sorted by go into the cursor
+	b, err := json.Marshal(cursor{Sort: q.Sort, Desc: q.Desc, Trade: TradeSummary{
+		Ticker:         last.Ticker,
+		MaxRangeValue:  last.MaxRangeValue,
+		MaxDailyVolume: last.MaxDailyVolume,
+	}})
+	if err != nil {
+		return TradePage{}, err
+	}
+
+	return TradePage{Trades: trades, NextCursor: base64.RawURLEncoding.EncodeToString(b)}, nil
+}
+
+// less tells whether a comes before b in the order of the query.
+func (q TradeQuery) less(a TradeSummary, b TradeSummary) bool {
+	c := 0
+
+	switch q.Sort {
+	case SortMaxRangeValue:
+		c = cmp.Compare(a.MaxRangeValue, b.MaxRangeValue)
+	case SortMaxDailyVolume:
+		c = cmp.Compare(a.MaxDailyVolume, b.MaxDailyVolume)
+	}
+
+	if c == 0 {
+		c = strings.Compare(a.Ticker, b.Ticker)
+	}
+
+	if q.Desc {
+		return c > 0
+	}
+
+	return c < 0
+}
+
+// orderBy fills in the column, direction, comparison and type of the sort
+// field in FETCH_TRADES_PAGE.
+func (q TradeQuery) orderBy(query string) string {
+	direction, comparison := "ASC", ">"
+	if q.Desc {
+		direction, comparison = "DESC", "<"
+	}
+
+	return fmt.Sprintf(query, q.Sort, direction, comparison, sortTypes[q.Sort])
+}
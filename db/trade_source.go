@@ -0,0 +1,10 @@
+package db
+
+// TradeSource streams trades into a bulk copy, in the same fashion as
+// pgx.CopyFromSource: Next advances to the next trade, Trade returns it and
+// Err reports what stopped the iteration, if anything.
+type TradeSource interface {
+	Next() bool
+	Trade() Trade
+	Err() error
+}
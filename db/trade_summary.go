@@ -1,7 +1,42 @@
 package db
 
+// TradeSummary summarizes the trades of a ticker in a period. The statistics
+// after MaxDailyVolume are nil when they cannot be computed, as for days
+// summarized before they existed and not refreshed since.
 type TradeSummary struct {
 	Ticker         string  `json:"ticker"`
 	MaxRangeValue  float64 `json:"max_range_value"`
 	MaxDailyVolume int64   `json:"max_daily_volume"`
+
+	// Open and Close are the prices of the first and last trades.
+	Open          *float64 `json:"open,omitempty"`
+	Close         *float64 `json:"close,omitempty"`
+	MinRangeValue *float64 `json:"min_range_value,omitempty"`
+	// VWAP is the average price weighted by quantity.
+	VWAP *float64 `json:"vwap,omitempty"`
+	// Volume is the quantity traded in the whole period.
+	Volume *int64 `json:"volume,omitempty"`
+	// FinancialVolume is the sum of price times quantity of the trades.
+	FinancialVolume *float64 `json:"financial_volume,omitempty"`
+	TradeCount      *int64   `json:"trade_count,omitempty"`
+	// AvgTradeSize is the average quantity of a trade.
+	AvgTradeSize *float64 `json:"avg_trade_size,omitempty"`
+}
+
+// columns returns the destinations of the columns selected for a summary by
+// the queries in sql.go and sqlite_sql.go, in order.
+func (t *TradeSummary) columns() []any {
+	return []any{
+		&t.Ticker,
+		&t.MaxRangeValue,
+		&t.MaxDailyVolume,
+		&t.Open,
+		&t.Close,
+		&t.MinRangeValue,
+		&t.VWAP,
+		&t.Volume,
+		&t.FinancialVolume,
+		&t.TradeCount,
+		&t.AvgTradeSize,
+	}
 }
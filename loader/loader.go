@@ -1,27 +1,65 @@
 package loader
 
 import (
-	"io"
+	"context"
+	"fmt"
 	"os"
 	"path/filepath"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/eu-ovictor/b3-market-data/db"
 	"github.com/schollz/progressbar/v3"
+	"golang.org/x/sync/errgroup"
 )
 
+// Modes of inserting trades into the database.
+const (
+	// ModeCopy streams every file through a single bulk copy.
+	ModeCopy = "copy"
+	// ModeBatch inserts trades in batches of INSERT statements.
+	ModeBatch = "batch"
+)
+
+// progressStep is how many rows are streamed by a bulk copy between progress
+// bar updates.
+const progressStep = 10_000
+
+// Options configures how files are loaded.
+type Options struct {
+	// BatchSize is the max length of rows inserted at once in batch mode.
+	BatchSize int
+	// Force reloads files the load manifest says were already loaded.
+	Force bool
+	// Mode is either ModeCopy or ModeBatch.
+	Mode string
+	// Workers is how many files are read at the same time. In copy mode it
+	// is also how many copies run at the same time.
+	Workers int
+	// InsertConcurrency is how many batches are inserted at the same time in
+	// batch mode. It is also how many batches wait for an insert worker
+	// before file readers block.
+	InsertConcurrency int
+}
+
 type loader struct {
-	db db.DB
+	db       db.DB
+	opts     Options
+	inserted *atomic.Int64
+	batches  chan batch
+	cancel   context.CancelCauseFunc
 }
 
+// processFile stores the trades of a file and, once they are all stored,
+// cancels the trades B3 reported as cancelled in the same file. The row count
+// and reference date of the file are recorded in m.
 func (l loader) processFile(
+	ctx context.Context,
 	filePath string,
-	batchSize int,
 	pbar *progressbar.ProgressBar,
-	wg *sync.WaitGroup,
+	m *db.Manifest,
 ) error {
-	batch := []db.Trade{}
-
 	r, err := newReader(filePath)
 	if err != nil {
 		return err
@@ -34,51 +72,151 @@ func (l loader) processFile(
 		return err
 	}
 
-	for {
-		row, err := r.Read()
+	src := newTradeSource(r, m)
 
-		if err != nil {
-			if err == io.EOF {
-				if err := l.db.InsertMany(batch); err != nil {
-					return err
-				}
+	if l.opts.Mode == ModeBatch {
+		err = l.insertBatches(ctx, filepath.Base(filePath), src)
+	} else {
+		err = l.copyTrades(ctx, src, pbar)
+	}
 
-				pbar.Add(len(batch))
+	if err != nil {
+		return err
+	}
 
-				break
-			}
-			return err
+	if len(src.cancellations) > 0 {
+		if err := l.db.CancelMany(ctx, src.cancellations); err != nil {
+			return fmt.Errorf("could not apply %d cancellations: %w", len(src.cancellations), err)
 		}
+	}
 
-		trade, err := processRow(row)
-		if err != nil {
-			return err
+	return nil
+}
+
+// insertBatches sends the trades read from src to the insert workers in
+// batches. It stops reading once the load is cancelled and returns the error
+// of the first batch that failed, along with the range of rows it holds.
+func (l loader) insertBatches(ctx context.Context, name string, src *tradeSource) error {
+	var first, last int64
+
+	file := fileBatches{name: name}
+
+	trades := []db.Trade{}
+
+	for ctx.Err() == nil && src.Next() {
+		if len(trades) == 0 {
+			first = src.Row()
 		}
 
-		batch = append(batch, trade)
+		last = src.Row()
+		trades = append(trades, src.Trade())
+
+		if len(trades) == l.opts.BatchSize {
+			l.send(ctx, batch{trades: trades, first: first, last: last, file: &file})
+
+			trades = []db.Trade{}
+		}
+	}
+
+	if len(trades) > 0 && src.Err() == nil {
+		l.send(ctx, batch{trades: trades, first: first, last: last, file: &file})
+	}
+
+	// cancellations must only run after the trades they cancel are inserted
+	if err := file.wait(); err != nil {
+		return err
+	}
+
+	if err := src.Err(); err != nil {
+		return err
+	}
+
+	return ctx.Err()
+}
+
+// progressSource reports the trades streamed from a source to a progress bar
+// and stops streaming once ctx is done.
+type progressSource struct {
+	db.TradeSource
+	ctx     context.Context
+	pbar    *progressbar.ProgressBar
+	pending int
+}
+
+func (s *progressSource) Next() bool {
+	if s.pending == progressStep {
+		s.pbar.Add(s.pending)
+		s.pending = 0
+	}
 
-		if len(batch) == batchSize {
-			wg.Add(1)
+	if s.ctx.Err() != nil || !s.TradeSource.Next() {
+		s.pbar.Add(s.pending)
+		s.pending = 0
+
+		return false
+	}
 
-			go func(wg *sync.WaitGroup, db db.DB, batch []db.Trade) {
-				defer wg.Done()
-				l.db.InsertMany(batch)
-			}(wg, l.db, batch)
+	s.pending++
 
-			pbar.Add(len(batch))
+	return true
+}
+
+func (s *progressSource) Err() error {
+	if err := s.TradeSource.Err(); err != nil {
+		return err
+	}
 
-			batch = []db.Trade{}
+	return s.ctx.Err()
+}
+
+// copyTrades streams the trades read from src straight into the database.
+// Since the copy runs in a single transaction, a failure rolls back every row
+// of the file.
+func (l loader) copyTrades(ctx context.Context, src *tradeSource, pbar *progressbar.ProgressBar) error {
+	n, err := l.db.CopyMany(ctx, &progressSource{TradeSource: src, ctx: ctx, pbar: pbar})
+	if err != nil {
+		if err := src.Err(); err != nil {
+			return err
 		}
+
+		return fmt.Errorf("rows 1 to %d: %w", src.Row(), err)
 	}
 
+	l.inserted.Add(n)
+	rowsInserted.Add(float64(n))
+
 	return nil
 }
 
-func Load(dir string, batchSize int, db db.DB) error {
+// Load reads the B3 files in dir and stores their trades in db. Files are
+// read by opts.Workers readers which, in batch mode, hand batches of trades
+// to opts.InsertConcurrency insert workers through a bounded channel.
+//
+// Once ctx is done no more rows are read. Batches and copies in flight are
+// cancelled, which rolls them back, and the files being loaded are marked as
+// failed so the next load retries them.
+func Load(ctx context.Context, dir string, db db.DB, opts Options) error {
+	if opts.Mode != ModeCopy && opts.Mode != ModeBatch {
+		return fmt.Errorf("invalid load mode %q, expected %s or %s", opts.Mode, ModeCopy, ModeBatch)
+	}
+
+	if opts.Workers < 1 || opts.InsertConcurrency < 1 {
+		return fmt.Errorf("expected at least one worker and one insert worker, got %d and %d", opts.Workers, opts.InsertConcurrency)
+	}
+
+	loadCtx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
 	loader := loader{
-		db: db,
+		db:       db,
+		opts:     opts,
+		inserted: &atomic.Int64{},
+		batches:  make(chan batch, opts.InsertConcurrency),
+		cancel:   cancel,
 	}
 
+	start := time.Now()
+
 	pbar := progressbar.Default(-1, "rows inserted")
 	defer pbar.Close()
 
@@ -87,34 +225,58 @@ func Load(dir string, batchSize int, db db.DB) error {
 		return err
 	}
 
-	var wg sync.WaitGroup
+	g, ctx := errgroup.WithContext(loadCtx)
+	g.SetLimit(opts.Workers)
+
+	var workers sync.WaitGroup
 
-	q := make(chan error, len(files))
+	for range opts.InsertConcurrency {
+		workers.Add(1)
+
+		go func() {
+			defer workers.Done()
+			loader.insertWorker(ctx, pbar)
+		}()
+	}
 
 	for _, file := range files {
+		if file.IsDir() {
+			continue
+		}
+
 		filePath := filepath.Join(dir, file.Name())
 
-		wg.Add(1)
+		g.Go(func() error {
+			return loader.loadFile(ctx, filePath, pbar)
+		})
+	}
 
-		go func(filePath string, wg *sync.WaitGroup, q chan<- error) {
-			defer wg.Done()
+	err = g.Wait()
 
-			err := loader.processFile(filePath, batchSize, pbar, wg)
+	close(loader.batches)
+	workers.Wait()
 
-			q <- err
-		}(filePath, &wg, q)
+	if cause := context.Cause(loadCtx); cause != nil {
+		return cause
 	}
 
-	go func(wg *sync.WaitGroup) {
-		wg.Wait()
-		close(q)
-	}(&wg)
-
-	for m := range q {
-		if m != nil {
-			return m
-		}
+	if err != nil {
+		return err
 	}
 
+	pbar.Finish()
+
+	elapsed := time.Since(start)
+	inserted := loader.inserted.Load()
+
+	fmt.Fprintf(
+		os.Stderr,
+		"\n%d rows inserted in %s using %s mode (%.0f rows/s)\n",
+		inserted,
+		elapsed.Round(time.Millisecond),
+		opts.Mode,
+		float64(inserted)/elapsed.Seconds(),
+	)
+
 	return nil
 }
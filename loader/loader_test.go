@@ -0,0 +1,234 @@
+package loader
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/eu-ovictor/b3-market-data/db"
+	"github.com/stretchr/testify/assert"
+)
+
+// failingDB fails every insert and keeps the manifests it was given.
+type failingDB struct {
+	db.DB
+	mu        sync.Mutex
+	manifests map[string]db.Manifest
+}
+
+func (f *failingDB) InsertMany(context.Context, []db.Trade) error { return errors.New("insert failed") }
+
+func (f *failingDB) CopyMany(context.Context, db.TradeSource) (int64, error) {
+	return 0, errors.New("copy failed")
+}
+
+func (f *failingDB) GetManifest(_ context.Context, fileName string) (db.Manifest, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.manifests[fileName], nil
+}
+
+func (f *failingDB) SaveManifest(_ context.Context, m db.Manifest) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.manifests[m.FileName] = m
+
+	return nil
+}
+
+func TestLoadReportsFailedBatch(t *testing.T) {
+	dir := t.TempDir()
+
+	writeTestFile(
+		t,
+		dir,
+		"2024-07-01.zip",
+		"2024-07-01;PETR4;0;37,510;100;100000123;10;1;2024-07-01;3;8",
+		"2024-07-01;PETR4;0;37,520;200;100001123;20;1;2024-07-01;3;8",
+	)
+
+	for _, mode := range []string{ModeBatch, ModeCopy} {
+		f := &failingDB{manifests: map[string]db.Manifest{}}
+
+		err := Load(context.Background(), dir, f, Options{BatchSize: 1, Mode: mode, Workers: 1, InsertConcurrency: 1})
+
+		assert.Error(t, err, "expected an error loading with %s mode", mode)
+		assert.Contains(t, err.Error(), "2024-07-01.zip: rows ", "expected the error to name the file and rows, got %s", err)
+		assert.Equal(t, f.manifests["2024-07-01.zip"].Status, db.ManifestFailed, "expected the file to be marked as failed, got %v", f.manifests["2024-07-01.zip"])
+	}
+}
+
+// recordingDB keeps every trade stored and cancelled, along with manifests.
+type recordingDB struct {
+	failingDB
+	trades        map[int64]db.Trade
+	cancelledMiss int
+}
+
+func (r *recordingDB) InsertMany(_ context.Context, trades []db.Trade) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, trade := range trades {
+		r.trades[trade.TradeID] = trade
+	}
+
+	return nil
+}
+
+func (r *recordingDB) CopyMany(_ context.Context, src db.TradeSource) (int64, error) {
+	var n int64
+
+	for src.Next() {
+		if err := r.InsertMany(context.Background(), []db.Trade{src.Trade()}); err != nil {
+			return 0, err
+		}
+
+		n++
+	}
+
+	return n, src.Err()
+}
+
+func (r *recordingDB) CancelMany(_ context.Context, trades []db.Trade) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, trade := range trades {
+		if _, ok := r.trades[trade.TradeID]; !ok {
+			r.cancelledMiss++
+		}
+
+		delete(r.trades, trade.TradeID)
+	}
+
+	return nil
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+
+	writeTestFile(
+		t,
+		dir,
+		"2024-07-01.zip",
+		"2024-07-01;PETR4;0;37,510;100;100000123;10;1;2024-07-01;3;8",
+		"2024-07-01;PETR4;0;37,520;200;100001123;20;1;2024-07-01;3;8",
+		"2024-07-01;PETR4;0;37,530;300;100002123;30;1;2024-07-01;3;8",
+		"2024-07-01;PETR4;2;37,510;100;100000123;10;1;2024-07-01;3;8",
+	)
+
+	writeTestFile(
+		t,
+		dir,
+		"2024-07-02.zip",
+		"2024-07-02;VALE3;0;60,100;100;100000123;40;1;2024-07-02;3;8",
+	)
+
+	for _, mode := range []string{ModeBatch, ModeCopy} {
+		r := &recordingDB{
+			failingDB: failingDB{manifests: map[string]db.Manifest{}},
+			trades:    map[int64]db.Trade{},
+		}
+
+		err := Load(context.Background(), dir, r, Options{BatchSize: 1, Mode: mode, Workers: 2, InsertConcurrency: 2})
+
+		assert.NoError(t, err, "expected no error loading with %s mode, got %s", mode, err)
+		assert.Equal(t, len(r.trades), 3, "expected 3 trades left after the cancellation, got %v", r.trades)
+		assert.Equal(t, r.cancelledMiss, 0, "expected cancellations to run after the trades they cancel are stored")
+		assert.Equal(t, r.manifests["2024-07-01.zip"].Status, db.ManifestLoaded, "expected the file to be marked as loaded, got %v", r.manifests["2024-07-01.zip"])
+		assert.Equal(t, r.manifests["2024-07-01.zip"].RowCount, int64(4), "expected row count to be 4, got %v", r.manifests["2024-07-01.zip"].RowCount)
+
+		err = Load(context.Background(), dir, r, Options{BatchSize: 1, Mode: mode, Workers: 2, InsertConcurrency: 2})
+
+		assert.NoError(t, err, "expected no error reloading with %s mode, got %s", mode, err)
+	}
+}
+
+func TestLoadChangedFile(t *testing.T) {
+	dir := t.TempDir()
+
+	writeTestFile(t, dir, "2024-07-01.zip", "2024-07-01;PETR4;0;37,510;100;100000123;10;1;2024-07-01;3;8")
+
+	r := &recordingDB{
+		failingDB: failingDB{manifests: map[string]db.Manifest{}},
+		trades:    map[int64]db.Trade{},
+	}
+
+	opts := Options{BatchSize: 1, Mode: ModeBatch, Workers: 1, InsertConcurrency: 1}
+
+	err := Load(context.Background(), dir, r, opts)
+	assert.NoError(t, err, "expected no error loading, got %s", err)
+
+	loaded := r.manifests["2024-07-01.zip"].SHA256
+
+	// the same file is skipped, so trades deleted meanwhile are not restored
+	delete(r.trades, 10)
+
+	err = Load(context.Background(), dir, r, opts)
+	assert.NoError(t, err, "expected no error reloading, got %s", err)
+	assert.Equal(t, len(r.trades), 0, "expected the unchanged file to be skipped, got %v", r.trades)
+
+	writeTestFile(
+		t,
+		dir,
+		"2024-07-01.zip",
+		"2024-07-01;PETR4;0;37,510;100;100000123;10;1;2024-07-01;3;8",
+		"2024-07-01;PETR4;0;37,520;200;100001123;20;1;2024-07-01;3;8",
+	)
+
+	err = Load(context.Background(), dir, r, opts)
+	assert.NoError(t, err, "expected no error reloading, got %s", err)
+	assert.Equal(t, len(r.trades), 2, "expected the changed file to be loaded again, got %v", r.trades)
+	assert.NotEqual(t, r.manifests["2024-07-01.zip"].SHA256, loaded, "expected the checksum of the changed file to be recorded")
+}
+
+func TestLoadIntoMemory(t *testing.T) {
+	dir := t.TempDir()
+
+	writeTestFile(
+		t,
+		dir,
+		"2024-07-01.zip",
+		"2024-07-01;PETR4;0;37,510;100;100000123;10;1;2024-07-01;3;8",
+		"2024-07-01;PETR4;0;37,990;200;100001123;20;1;2024-07-01;3;8",
+		"2024-07-01;PETR4;2;37,990;200;100001123;20;1;2024-07-01;3;8",
+	)
+
+	m := db.NewMemory()
+
+	err := Load(context.Background(), dir, m, Options{BatchSize: 1000, Mode: ModeCopy, Workers: 1, InsertConcurrency: 1})
+	assert.NoError(t, err, "expected no error loading, got %s", err)
+
+	trade, err := m.GetTrade(context.Background(), "PETR4", "", "")
+	assert.NoError(t, err, "expected no error getting summary, got %s", err)
+
+	assert.Equal(t, trade.MaxRangeValue, 37.51, "expected the cancelled trade to be left out of max range value, got %v", trade.MaxRangeValue)
+	assert.Equal(t, trade.MaxDailyVolume, int64(100), "expected the cancelled trade to be left out of max daily volume, got %v", trade.MaxDailyVolume)
+}
+
+func TestLoadCorrection(t *testing.T) {
+	dir := t.TempDir()
+
+	writeTestFile(
+		t,
+		dir,
+		"2024-07-01.zip",
+		"2024-07-01;PETR4;0;37,510;100;100000123;10;1;2024-07-01;3;8",
+		"2024-07-01;PETR4;1;37,490;300;100002123;10;1;2024-07-01;3;8",
+	)
+
+	m := db.NewMemory()
+
+	err := Load(context.Background(), dir, m, Options{BatchSize: 1000, Mode: ModeCopy, Workers: 1, InsertConcurrency: 1})
+	assert.NoError(t, err, "expected no error loading, got %s", err)
+
+	trade, err := m.GetTrade(context.Background(), "PETR4", "", "")
+	assert.NoError(t, err, "expected no error getting summary, got %s", err)
+
+	assert.Equal(t, trade.MaxRangeValue, 37.49, "expected the correction to replace the price of the trade, got %v", trade.MaxRangeValue)
+	assert.Equal(t, trade.MaxDailyVolume, int64(300), "expected the correction to replace the quantity of the trade, got %v", trade.MaxDailyVolume)
+}
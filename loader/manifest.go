@@ -0,0 +1,99 @@
+package loader
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/eu-ovictor/b3-market-data/db"
+	"github.com/schollz/progressbar/v3"
+)
+
+// checksum returns the hex encoded SHA-256 of a file.
+func checksum(filePath string) (string, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// loadFile processes a file unless the load manifest says it was already
+// loaded with the same checksum, recording the outcome of the load in the
+// manifest. A loaded file whose contents changed, as when B3 publishes a
+// file again, is loaded again.
+func (l loader) loadFile(ctx context.Context, filePath string, pbar *progressbar.ProgressBar) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	name := filepath.Base(filePath)
+
+	m, err := l.db.GetManifest(ctx, name)
+	if err != nil {
+		return err
+	}
+
+	sum, err := checksum(filePath)
+	if err != nil {
+		return err
+	}
+
+	if m.Status == db.ManifestLoaded && !l.opts.Force {
+		if m.SHA256 == sum {
+			fmt.Fprintf(os.Stderr, "skipping %s, already loaded\n", name)
+			filesProcessed.WithLabelValues("skipped").Inc()
+			return nil
+		}
+
+		fmt.Fprintf(os.Stderr, "loading %s again, its checksum changed since it was loaded\n", name)
+	}
+
+	m = db.Manifest{
+		FileName:  name,
+		SHA256:    sum,
+		Status:    db.ManifestLoading,
+		StartedAt: time.Now(),
+	}
+
+	if err := l.db.SaveManifest(ctx, m); err != nil {
+		return err
+	}
+
+	err = l.processFile(ctx, filePath, pbar, &m)
+
+	m.FinishedAt = time.Now()
+	m.Status = db.ManifestLoaded
+
+	if err != nil {
+		m.Status = db.ManifestFailed
+		filesProcessed.WithLabelValues(m.Status).Inc()
+
+		err = fmt.Errorf("could not load %s: %w", name, err)
+
+		// the failure is recorded even when it comes from ctx being cancelled
+		if saveErr := l.db.SaveManifest(context.WithoutCancel(ctx), m); saveErr != nil {
+			return errors.Join(err, saveErr)
+		}
+
+		return err
+	}
+
+	filesProcessed.WithLabelValues(m.Status).Inc()
+
+	return l.db.SaveManifest(ctx, m)
+}
@@ -0,0 +1,20 @@
+package loader
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// These counters are registered in the default Prometheus registry, so they
+// are exported by the API when a load runs in the same process.
+var (
+	rowsInserted = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "b3_loader_rows_inserted_total",
+		Help: "Trades inserted by the loader.",
+	})
+
+	filesProcessed = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "b3_loader_files_total",
+		Help: "Files processed by the loader, by status: loaded, failed or skipped.",
+	}, []string{"status"})
+)
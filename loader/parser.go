@@ -1,27 +1,57 @@
 package loader
 
 import (
+	"fmt"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/eu-ovictor/b3-market-data/db"
 )
 
-func parseEntryTime(s string) (time.Time, error) {
-	loc, err := time.LoadLocation("America/Sao_Paulo")
+// Columns of the B3 TradeIntraday file, in the order they appear in it.
+const (
+	referenceDateColumn = iota // DataReferencia
+	tickerColumn               // CodigoInstrumento
+	updateActionColumn         // AcaoAtualizacao
+	grossAmountColumn          // PrecoNegocio
+	quantityColumn             // QuantidadeNegociada
+	entryTimeColumn            // HoraFechamento
+	tradeIDColumn              // CodigoIdentificadorNegocio
+	sessionTypeColumn          // TipoSessaoPregao
+	tradeDateColumn            // DataNegocio
+	buyerCodeColumn            // CodigoParticipanteComprador
+	sellerCodeColumn           // CodigoParticipanteVendedor
+	columnCount
+)
+
+const (
+	dateLayout      = "2006-01-02"
+	entryTimeLayout = "2006-01-02 150405.000"
+)
+
+// loadLocation loads B3's time zone once, since every row is parsed against it.
+var loadLocation = sync.OnceValues(func() (*time.Location, error) {
+	return time.LoadLocation("America/Sao_Paulo")
+})
+
+// parseEntryTime combines the trade date (DataNegocio) with the closing time
+// (HoraFechamento, formatted as HHMMSSmmm) into a single timestamp in the
+// America/Sao_Paulo time zone.
+func parseEntryTime(date string, hour string) (time.Time, error) {
+	loc, err := loadLocation()
 	if err != nil {
 		return time.Time{}, err
 	}
 
-	hour, _ := strconv.Atoi(s[0:2])
-	minute, _ := strconv.Atoi(s[2:4])
-	second, _ := strconv.Atoi(s[4:6])
-	nano, _ := strconv.Atoi(s[6:9])
+	if len(hour) > 9 {
+		return time.Time{}, fmt.Errorf("invalid entry time %q", hour)
+	}
 
-	entryTime := time.Date(0, 1, 1, hour, minute, second, nano*1000000, loc)
+	hour = strings.Repeat("0", 9-len(hour)) + hour
 
-	return entryTime, nil
+	return time.ParseInLocation(entryTimeLayout, fmt.Sprintf("%s %s.%s", date, hour[0:6], hour[6:9]), loc)
 }
 
 func parseGrossAmount(s string) (float64, error) {
@@ -30,35 +60,84 @@ func parseGrossAmount(s string) (float64, error) {
 	return strconv.ParseFloat(s, 64)
 }
 
+// parseOptionalInt parses columns B3 may leave blank, such as the broker
+// codes, returning zero for blank values.
+func parseOptionalInt(s string) (int, error) {
+	if s == "" {
+		return 0, nil
+	}
+
+	return strconv.Atoi(s)
+}
+
 func processRow(row []string) (db.Trade, error) {
-	ticker := row[1]
+	if len(row) < columnCount {
+		return db.Trade{}, fmt.Errorf("expected %d columns, got %d", columnCount, len(row))
+	}
+
+	ticker := row[tickerColumn]
+
+	referenceDate, err := time.Parse(dateLayout, row[referenceDateColumn])
+	if err != nil {
+		return db.Trade{}, err
+	}
+
+	updateAction, err := strconv.Atoi(row[updateActionColumn])
+	if err != nil {
+		return db.Trade{}, err
+	}
+
+	switch updateAction {
+	case db.UpdateActionNew, db.UpdateActionCorrect, db.UpdateActionCancel:
+	default:
+		return db.Trade{}, fmt.Errorf("unknown update action %d", updateAction)
+	}
+
+	grossAmount, err := parseGrossAmount(row[grossAmountColumn])
+	if err != nil {
+		return db.Trade{}, err
+	}
+
+	quantity, err := strconv.ParseInt(row[quantityColumn], 10, 64)
+	if err != nil {
+		return db.Trade{}, err
+	}
+
+	entryTime, err := parseEntryTime(row[tradeDateColumn], row[entryTimeColumn])
+	if err != nil {
+		return db.Trade{}, err
+	}
 
-	grossAmount, err := parseGrossAmount(row[3])
+	tradeID, err := strconv.ParseInt(row[tradeIDColumn], 10, 64)
 	if err != nil {
 		return db.Trade{}, err
 	}
 
-	quantity, err := strconv.ParseInt(row[4], 10, 64)
+	sessionType, err := strconv.Atoi(row[sessionTypeColumn])
 	if err != nil {
 		return db.Trade{}, err
 	}
 
-	entryTime, err := parseEntryTime(row[5])
+	buyerCode, err := parseOptionalInt(row[buyerCodeColumn])
 	if err != nil {
 		return db.Trade{}, err
 	}
 
-	layout := "2006-01-02"
-	date, err := time.Parse(layout, row[8])
+	sellerCode, err := parseOptionalInt(row[sellerCodeColumn])
 	if err != nil {
 		return db.Trade{}, err
 	}
 
 	return db.Trade{
-		Ticker:      ticker,
-		GrossAmount: grossAmount,
-		Quantity:    quantity,
-		EntryTime:   entryTime,
-		Date:        date,
+		Ticker:        ticker,
+		GrossAmount:   grossAmount,
+		Quantity:      quantity,
+		EntryTime:     entryTime,
+		ReferenceDate: referenceDate,
+		UpdateAction:  updateAction,
+		TradeID:       tradeID,
+		SessionType:   sessionType,
+		BuyerCode:     buyerCode,
+		SellerCode:    sellerCode,
 	}, nil
 }
@@ -13,12 +13,15 @@ func TestParseEntryTime(t *testing.T) {
 	second := 59
 	nanosecond := 559
 
+	date := "2024-07-01"
 	entryTimeStr := fmt.Sprintf("%v%v%v%v", hour, minute, second, nanosecond)
 
-	entryTime, err := parseEntryTime(entryTimeStr)
+	entryTime, err := parseEntryTime(date, entryTimeStr)
 
 	assert.NoError(t, err, "expected no error parsing entry time, got: %v", err)
 
+	assert.Equal(t, entryTime.Format(dateLayout), date, "expected date to be %v, got: %v", date, entryTime.Format(dateLayout))
+	assert.Equal(t, entryTime.Location().String(), "America/Sao_Paulo", "expected location to be America/Sao_Paulo, got: %v", entryTime.Location())
 	assert.Equal(t, entryTime.Hour(), hour, "expected hour to be %v, got: %v", hour+3, entryTime.Hour())
 	assert.Equal(t, entryTime.Minute(), minute, "expected minute to be %v, got: %v", minute, entryTime.Minute())
 	assert.Equal(t, entryTime.Second(), second, "expected second to be %v, got: %v", second, entryTime.Second())
@@ -29,3 +32,47 @@ func TestParseEntryTime(t *testing.T) {
 		"expected nanosecond to be %v, got: %v", nanosecond*1000000, entryTime.Hour(),
 	)
 }
+
+func TestParseEntryTimeWithoutLeadingZero(t *testing.T) {
+	entryTime, err := parseEntryTime("2024-07-01", "95959001")
+
+	assert.NoError(t, err, "expected no error parsing entry time, got: %v", err)
+
+	assert.Equal(t, entryTime.Hour(), 9, "expected hour to be %v, got: %v", 9, entryTime.Hour())
+	assert.Equal(t, entryTime.Minute(), 59, "expected minute to be %v, got: %v", 59, entryTime.Minute())
+	assert.Equal(t, entryTime.Nanosecond(), 1000000, "expected nanosecond to be %v, got: %v", 1000000, entryTime.Nanosecond())
+}
+
+func TestProcessRow(t *testing.T) {
+	row := []string{"2024-07-01", "PETR4", "0", "37,510", "100", "100000123", "10", "1", "2024-07-01", "3", ""}
+
+	trade, err := processRow(row)
+
+	assert.NoError(t, err, "expected no error processing row, got: %v", err)
+
+	assert.Equal(t, trade.Ticker, "PETR4", "expected ticker to be %v, got: %v", "PETR4", trade.Ticker)
+	assert.Equal(t, trade.GrossAmount, 37.51, "expected gross amount to be %v, got: %v", 37.51, trade.GrossAmount)
+	assert.Equal(t, trade.Quantity, int64(100), "expected quantity to be %v, got: %v", 100, trade.Quantity)
+	assert.Equal(t, trade.ReferenceDate.Format(dateLayout), "2024-07-01", "expected reference date to be %v, got: %v", "2024-07-01", trade.ReferenceDate)
+	assert.Equal(t, trade.UpdateAction, 0, "expected update action to be %v, got: %v", 0, trade.UpdateAction)
+	assert.Equal(t, trade.TradeID, int64(10), "expected trade ID to be %v, got: %v", 10, trade.TradeID)
+	assert.Equal(t, trade.SessionType, 1, "expected session type to be %v, got: %v", 1, trade.SessionType)
+	assert.Equal(t, trade.BuyerCode, 3, "expected buyer code to be %v, got: %v", 3, trade.BuyerCode)
+	assert.Equal(t, trade.SellerCode, 0, "expected seller code to be %v, got: %v", 0, trade.SellerCode)
+}
+
+func TestProcessShortRow(t *testing.T) {
+	row := []string{"2024-07-01", "PETR4", "0", "37,510", "100", "100000123", "10", "1", "2024-07-01"}
+
+	_, err := processRow(row)
+
+	assert.EqualError(t, err, "expected 11 columns, got 9", "expected an error processing a short row, got: %v", err)
+}
+
+func TestProcessRowWithUnknownUpdateAction(t *testing.T) {
+	row := []string{"2024-07-01", "PETR4", "5", "37,510", "100", "100000123", "10", "1", "2024-07-01", "3", ""}
+
+	_, err := processRow(row)
+
+	assert.EqualError(t, err, "unknown update action 5", "expected an error processing an unknown update action, got: %v", err)
+}
@@ -0,0 +1,85 @@
+package loader
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/eu-ovictor/b3-market-data/db"
+	"github.com/schollz/progressbar/v3"
+)
+
+// batch is a slice of rows of a file waiting for an insert worker.
+type batch struct {
+	trades []db.Trade
+	first  int64
+	last   int64
+	file   *fileBatches
+}
+
+// fileBatches tracks the batches of a file handed to insert workers, so the
+// file reader knows when they are all stored and whether any of them failed.
+type fileBatches struct {
+	name string
+	wg   sync.WaitGroup
+	mu   sync.Mutex
+	err  error
+}
+
+func (f *fileBatches) fail(err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.err == nil {
+		f.err = err
+	}
+}
+
+// wait blocks until every batch of the file is processed and returns the
+// error of the first one that failed.
+func (f *fileBatches) wait() error {
+	f.wg.Wait()
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.err
+}
+
+// send hands a batch to the insert workers, blocking while they are busy. It
+// returns false if ctx is done before any worker takes the batch.
+func (l loader) send(ctx context.Context, b batch) bool {
+	b.file.wg.Add(1)
+
+	select {
+	case l.batches <- b:
+		return true
+	case <-ctx.Done():
+		b.file.wg.Done()
+		return false
+	}
+}
+
+// insertWorker inserts the batches sent by file readers until the batches
+// channel is closed. After the first failure it cancels the load and only
+// drains the channel, so readers waiting on their batches are released. The
+// failure is the cause of the cancellation, so Load reports it instead of the
+// cancellation of other files.
+func (l loader) insertWorker(ctx context.Context, pbar *progressbar.ProgressBar) {
+	for b := range l.batches {
+		if ctx.Err() == nil {
+			if err := l.db.InsertMany(ctx, b.trades); err != nil {
+				err = fmt.Errorf("rows %d to %d: %w", b.first, b.last, err)
+
+				b.file.fail(err)
+				l.cancel(fmt.Errorf("could not load %s: %w", b.file.name, err))
+			} else {
+				pbar.Add(len(b.trades))
+				l.inserted.Add(int64(len(b.trades)))
+				rowsInserted.Add(float64(len(b.trades)))
+			}
+		}
+
+		b.file.wg.Done()
+	}
+}
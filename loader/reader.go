@@ -3,6 +3,7 @@ package loader
 import (
 	"archive/zip"
 	"encoding/csv"
+	"fmt"
 	"io"
 	"path/filepath"
 )
@@ -36,7 +37,8 @@ func newReader(filePath string) (tradeReader, error) {
 	}
 
 	if tradesFile == nil {
-		return tradeReader{}, nil
+		zf.Close()
+		return tradeReader{}, fmt.Errorf("could not find a trades file in %s", filePath)
 	}
 
 	f, err := tradesFile.Open()
@@ -0,0 +1,68 @@
+package loader
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/eu-ovictor/b3-market-data/db"
+)
+
+// tradeSource reads the trades of a B3 file one row at a time, implementing
+// db.TradeSource so they can be streamed straight into a bulk copy.
+// Cancellations are kept apart, since they can only be applied once the
+// trades they cancel are stored.
+type tradeSource struct {
+	reader        tradeReader
+	manifest      *db.Manifest
+	trade         db.Trade
+	cancellations []db.Trade
+	err           error
+}
+
+func newTradeSource(r tradeReader, m *db.Manifest) *tradeSource {
+	return &tradeSource{
+		reader:   r,
+		manifest: m,
+	}
+}
+
+func (s *tradeSource) Next() bool {
+	for {
+		row, err := s.reader.Read()
+		if err != nil {
+			if err != io.EOF {
+				s.err = err
+			}
+
+			return false
+		}
+
+		trade, err := processRow(row)
+		if err != nil {
+			s.err = fmt.Errorf("row %d: %w", s.manifest.RowCount+1, err)
+			return false
+		}
+
+		if s.manifest.RowCount == 0 {
+			s.manifest.ReferenceDate = trade.ReferenceDate
+		}
+
+		s.manifest.RowCount++
+
+		if trade.IsCancellation() {
+			s.cancellations = append(s.cancellations, trade)
+			continue
+		}
+
+		s.trade = trade
+
+		return true
+	}
+}
+
+func (s *tradeSource) Trade() db.Trade { return s.trade }
+
+func (s *tradeSource) Err() error { return s.err }
+
+// Row returns the number of the last row read, not counting the header.
+func (s *tradeSource) Row() int64 { return s.manifest.RowCount }
@@ -0,0 +1,78 @@
+package loader
+
+import (
+	"archive/zip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/eu-ovictor/b3-market-data/db"
+	"github.com/stretchr/testify/assert"
+)
+
+const header = "DataReferencia;CodigoInstrumento;AcaoAtualizacao;PrecoNegocio;QuantidadeNegociada;HoraFechamento;CodigoIdentificadorNegocio;TipoSessaoPregao;DataNegocio;CodigoParticipanteComprador;CodigoParticipanteVendedor"
+
+// writeTestFile writes rows to a zip file shaped like the ones B3 publishes.
+func writeTestFile(t *testing.T, dir string, name string, rows ...string) string {
+	t.Helper()
+
+	filePath := filepath.Join(dir, name)
+
+	f, err := os.Create(filePath)
+	if err != nil {
+		t.Fatalf("expected no error creating test file, got %s", err)
+	}
+	defer f.Close()
+
+	zw := zip.NewWriter(f)
+
+	w, err := zw.Create(strings.TrimSuffix(name, filepath.Ext(name)) + ".txt")
+	if err != nil {
+		t.Fatalf("expected no error creating zip entry, got %s", err)
+	}
+
+	if _, err := w.Write([]byte(strings.Join(append([]string{header}, rows...), "\n"))); err != nil {
+		t.Fatalf("expected no error writing zip entry, got %s", err)
+	}
+
+	if err := zw.Close(); err != nil {
+		t.Fatalf("expected no error closing zip file, got %s", err)
+	}
+
+	return filePath
+}
+
+func TestTradeSource(t *testing.T) {
+	filePath := writeTestFile(
+		t,
+		t.TempDir(),
+		"2024-07-01.zip",
+		"2024-07-01;PETR4;0;37,510;100;100000123;10;1;2024-07-01;3;8",
+		"2024-07-01;PETR4;0;37,520;200;100001123;20;1;2024-07-01;3;8",
+		"2024-07-01;PETR4;2;37,510;100;100000123;10;1;2024-07-01;3;8",
+	)
+
+	r, err := newReader(filePath)
+	assert.NoError(t, err, "expected no error opening file, got %s", err)
+	defer r.Close()
+
+	_, err = r.Read()
+	assert.NoError(t, err, "expected no error reading header, got %s", err)
+
+	var m db.Manifest
+
+	src := newTradeSource(r, &m)
+
+	trades := []db.Trade{}
+	for src.Next() {
+		trades = append(trades, src.Trade())
+	}
+
+	assert.NoError(t, src.Err(), "expected no error reading trades, got %s", src.Err())
+	assert.Equal(t, len(trades), 2, "expected 2 trades, got %v", trades)
+	assert.Equal(t, len(src.cancellations), 1, "expected a single cancellation, got %v", src.cancellations)
+	assert.Equal(t, src.cancellations[0].TradeID, int64(10), "expected trade 10 to be cancelled, got %v", src.cancellations[0].TradeID)
+	assert.Equal(t, m.RowCount, int64(3), "expected row count to be %v, got %v", 3, m.RowCount)
+	assert.Equal(t, m.ReferenceDate.Format(dateLayout), "2024-07-01", "expected reference date to be %v, got %v", "2024-07-01", m.ReferenceDate)
+}